@@ -0,0 +1,148 @@
+package ifupdown
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Interfaces are put in classes by auto and allow-<class> lines. ifup -a
+// brings up the auto class, ifup --allow=<class> any other. auto is the same
+// as allow-auto.
+const (
+	ClassAuto    = "auto"
+	ClassHotplug = "hotplug"
+)
+
+// isClassLine reports whether keyword starts an auto or allow-* line.
+func isClassLine(keyword string) bool {
+	_, ok := allowClass(keyword)
+	return ok
+}
+
+// allowClass returns the class an auto or allow-<class> line is for.
+func allowClass(keyword string) (string, bool) {
+	switch {
+	case keyword == "auto":
+		return ClassAuto, true
+	case strings.HasPrefix(keyword, "allow-") && len(keyword) > len("allow-"):
+		return strings.TrimPrefix(keyword, "allow-"), true
+	default:
+		return "", false
+	}
+}
+
+// classKeyword is the inverse of allowClass.
+func classKeyword(class string) string {
+	if class == ClassAuto {
+		return "auto"
+	}
+	return "allow-" + class
+}
+
+// normalizeClass accepts a class by name or by the keyword of its lines.
+func normalizeClass(class string) string {
+	if c, ok := allowClass(class); ok {
+		return c
+	}
+	return class
+}
+
+// allow puts iface in class. The caller must hold the write lock.
+func (iface *NetworkInterface) allow(class string) {
+	switch class = normalizeClass(class); class {
+	case ClassAuto:
+		iface.Auto = true
+	case ClassHotplug:
+		iface.Hotplug = true
+	default:
+		if !slices.Contains(iface.Allow, class) {
+			iface.Allow = append(iface.Allow, class)
+		}
+	}
+}
+
+// classes lists the classes of iface: auto and hotplug first, then the others
+// in the order they were added. The caller must hold at least the read lock.
+func (iface *NetworkInterface) classes() []string {
+	var classes []string
+	if iface.Auto {
+		classes = append(classes, ClassAuto)
+	}
+	if iface.Hotplug {
+		classes = append(classes, ClassHotplug)
+	}
+	for _, class := range iface.Allow {
+		if class != ClassAuto && class != ClassHotplug && !slices.Contains(classes, class) {
+			classes = append(classes, class)
+		}
+	}
+	return classes
+}
+
+// Classes returns every class iface is in, auto and hotplug first.
+func (iface *NetworkInterface) Classes() []string {
+	iface.mu.RLock()
+	defer iface.mu.RUnlock()
+	return iface.classes()
+}
+
+// InClass reports whether iface is in class, given by name (hotplug) or by
+// the keyword of its lines (allow-hotplug).
+func (iface *NetworkInterface) InClass(class string) bool {
+	iface.mu.RLock()
+	defer iface.mu.RUnlock()
+	return slices.Contains(iface.classes(), normalizeClass(class))
+}
+
+// WithAllow puts the interface in class, as an allow-<class> line would.
+func (iface *NetworkInterface) WithAllow(class string) *NetworkInterface {
+	iface.allocate()
+	iface.allow(class)
+	iface.mu.Unlock()
+	return iface
+}
+
+// ByClass returns the sorted names of the interfaces in class, like
+// ifquery --list --allow=<class>. class is auto, hotplug or the name of any
+// other allow-<class> line.
+func (i Interfaces) ByClass(class string) []string {
+	var names []string
+	for _, name := range i.names() {
+		if i[name] != nil && i[name].InClass(class) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// classLines returns an auto or allow-<class> line for every class in i, each
+// naming all of its interfaces. auto and allow-hotplug come first, the other
+// classes follow in alphabetical order.
+func (i Interfaces) classLines() []string {
+	members := make(map[string][]string)
+	for _, name := range i.names() {
+		if i[name] == nil {
+			continue
+		}
+		for _, class := range i[name].Classes() {
+			members[class] = append(members[class], name)
+		}
+	}
+
+	var others []string
+	for class := range members {
+		if class != ClassAuto && class != ClassHotplug {
+			others = append(others, class)
+		}
+	}
+	sort.Strings(others)
+
+	var lines []string
+	for _, class := range append([]string{ClassAuto, ClassHotplug}, others...) {
+		if len(members[class]) > 0 {
+			lines = append(lines, classKeyword(class)+" "+strings.Join(members[class], " ")+"\n")
+		}
+	}
+	return lines
+}
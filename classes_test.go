@@ -0,0 +1,146 @@
+package ifupdown
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const classFile = `auto lo eth0
+allow-hotplug eth1 usb0
+allow-mobile usb0
+allow-auto eth2
+
+iface lo inet loopback
+
+iface eth0 inet dhcp
+
+iface eth1 inet dhcp
+
+allow-mobile wlan0
+iface wlan0 inet dhcp
+
+iface eth2 inet manual
+
+iface usb0 inet dhcp
+
+iface eth3 inet manual
+`
+
+func TestInterfaces_ByClass(t *testing.T) {
+	ifaces := parseInterfaces(t, classFile)
+
+	for class, want := range map[string][]string{
+		"auto":          {"eth0", "eth2", "lo"},
+		"allow-auto":    {"eth0", "eth2", "lo"},
+		"hotplug":       {"eth1", "usb0"},
+		"allow-hotplug": {"eth1", "usb0"},
+		"mobile":        {"usb0", "wlan0"},
+		"nothing":       nil,
+	} {
+		if got := ifaces.ByClass(class); !slices.Equal(got, want) {
+			t.Errorf("ByClass(%q) = %v, want %v", class, got, want)
+		}
+	}
+
+	if got := ifaces["usb0"].Classes(); !slices.Equal(got, []string{"hotplug", "mobile"}) {
+		t.Errorf("usb0 classes = %v", got)
+	}
+	if ifaces["eth3"].InClass("auto") || len(ifaces["eth3"].Classes()) != 0 {
+		t.Errorf("eth3 classes = %v, want none", ifaces["eth3"].Classes())
+	}
+}
+
+func TestInterfaces_WriteTo_Classes(t *testing.T) {
+	ifaces := parseInterfaces(t, classFile)
+	ifaces["eth3"].WithAllow("allow-lab").WithAllow("mobile")
+
+	out := ifaces.String()
+	header := "auto eth0 eth2 lo\n" +
+		"allow-hotplug eth1 usb0\n" +
+		"allow-lab eth3\n" +
+		"allow-mobile eth3 usb0 wlan0\n" +
+		"\n" +
+		"iface eth0 inet dhcp\n"
+	if !strings.HasPrefix(out, header) {
+		t.Errorf("String() =\n%s\nwant it to start with\n%s", out, header)
+	}
+
+	again := parseInterfaces(t, out)
+	for _, name := range ifaces.names() {
+		if got, want := again[name].Classes(), ifaces[name].Classes(); !slices.Equal(got, want) {
+			t.Errorf("[%s] classes after round trip = %v, want %v", name, got, want)
+		}
+	}
+
+	// a single interface is written with its own class lines
+	if got := ifaces["usb0"].String(); !strings.HasPrefix(got, "allow-hotplug usb0\nallow-mobile usb0\niface usb0") {
+		t.Errorf("usb0 String() = %q", got)
+	}
+}
+
+func TestNetworkInterface_Allow_JSON(t *testing.T) {
+	iface := NewNetworkInterface("usb0").WithDHCP().WithAllow("mobile")
+	data, err := json.Marshal(iface)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"allow":["mobile"]`) {
+		t.Errorf("MarshalJSON() = %s", data)
+	}
+	back := &NetworkInterface{}
+	if err = json.Unmarshal(data, back); err != nil {
+		t.Fatal(err)
+	}
+	if !back.InClass("allow-mobile") || !back.InClass("auto") {
+		t.Errorf("classes after JSON round trip = %v", back.Classes())
+	}
+	if clone := iface.Clone(); !slices.Equal(clone.Classes(), iface.Classes()) {
+		t.Errorf("Clone() classes = %v", clone.Classes())
+	}
+}
+
+func TestMultiParser_AutoLines(t *testing.T) {
+	ifaces := parseInterfaces(t, `iface eth0 inet dhcp
+
+iface eth1 inet dhcp
+
+auto lo eth0 eth1
+iface lo inet loopback
+
+iface eth2 inet dhcp
+allow-hotplug eth0
+`)
+	if got := ifaces.ByClass("auto"); !slices.Equal(got, []string{"eth0", "eth1", "lo"}) {
+		t.Errorf("ByClass(auto) = %v", got)
+	}
+	if got := ifaces.ByClass("hotplug"); !slices.Equal(got, []string{"eth0"}) {
+		t.Errorf("ByClass(hotplug) = %v", got)
+	}
+	if ifaces["eth2"].Auto {
+		t.Error("eth2 is not named on any auto line, but Auto = true")
+	}
+}
+
+func TestParseFS_AutoLines(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/network/interfaces": {Data: []byte(
+			"auto lo eth0 eth1\niface lo inet loopback\n\nsource interfaces.d/*\n\nallow-hotplug eth2\n",
+		)},
+		"etc/network/interfaces.d/eth0": {Data: []byte("iface eth0 inet dhcp\n")},
+		"etc/network/interfaces.d/eth1": {Data: []byte("iface eth1 inet dhcp\n")},
+		"etc/network/interfaces.d/eth2": {Data: []byte("iface eth2 inet dhcp\n")},
+	}
+	ifaces, err := ParseFS(fsys, "/etc/network/interfaces")
+	if err != nil {
+		t.Fatalf("ParseFS() = %v", err)
+	}
+	if got := ifaces.ByClass("auto"); !slices.Equal(got, []string{"eth0", "eth1", "lo"}) {
+		t.Errorf("ByClass(auto) = %v", got)
+	}
+	if got := ifaces.ByClass("hotplug"); !slices.Equal(got, []string{"eth2"}) {
+		t.Errorf("ByClass(hotplug) = %v", got)
+	}
+}
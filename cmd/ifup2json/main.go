@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"flag"
 	"io"
 	"os"
 
@@ -11,9 +12,12 @@ import (
 )
 
 func main() {
+	redact := flag.Bool("redact", false, "mask secrets such as wpa-psk")
+	flag.Parse()
+
 	ifaces := iface.NewMultiParser()
 	switch {
-	case len(os.Args) < 2:
+	case flag.NArg() == 0:
 		buf := &bytes.Buffer{}
 		var empty = 0
 		for {
@@ -39,7 +43,7 @@ func main() {
 			panic("short write")
 		}
 	default:
-		dat, err := os.ReadFile(os.Args[1])
+		dat, err := os.ReadFile(flag.Arg(0))
 		if err != nil {
 			panic(err)
 		}
@@ -67,6 +71,10 @@ func main() {
 		}
 	}
 
+	if *redact {
+		imap = imap.Redacted()
+	}
+
 	dat, err := json.MarshalIndent(imap, "", "\t")
 	_, _ = os.Stdout.Write(dat)
 }
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+
+	iface "git.tcp.direct/kayos/ifupdown"
+	"git.tcp.direct/kayos/ifupdown/live"
+)
+
+const usage = `usage: ifupdown <command> [flags]
+
+commands:
+  split        write each interface into its own file under interfaces.d
+  consolidate  merge sourced fragments back into a single interfaces file
+  drift        compare the configuration with the running state of the host
+  lint         check the hooks of every interface for shell mistakes and missing teardown
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		print(usage)
+		os.Exit(2)
+	}
+
+	var err error
+
+	switch os.Args[1] {
+	case "split":
+		err = split(os.Args[2:])
+	case "consolidate":
+		err = consolidate(os.Args[2:])
+	case "drift":
+		os.Exit(int(drift(os.Args[2:])))
+	case "lint":
+		var found bool
+		found, err = lint(os.Args[2:])
+		if err == nil && found {
+			os.Exit(1)
+		}
+	default:
+		print(usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		println(err.Error())
+		os.Exit(1)
+	}
+}
+
+func split(args []string) error {
+	fset := flag.NewFlagSet("split", flag.ExitOnError)
+	main := fset.String("main", "/etc/network/interfaces", "main interfaces file")
+	dir := fset.String("dir", "/etc/network/interfaces.d", "directory to write fragments into, relative to the main file unless absolute")
+	backups := fset.Int("backups", 3, "number of backups to keep of every replaced file")
+	_ = fset.Parse(args)
+
+	ifaces, err := iface.ParseFile(*main)
+	if err != nil {
+		return err
+	}
+	_, err = ifaces.WriteFragments(*main, *dir, &iface.WriteOptions{Backups: *backups})
+	return err
+}
+
+func consolidate(args []string) error {
+	fset := flag.NewFlagSet("consolidate", flag.ExitOnError)
+	main := fset.String("main", "/etc/network/interfaces", "main interfaces file")
+	backups := fset.Int("backups", 3, "number of backups to keep of the main file")
+	_ = fset.Parse(args)
+
+	_, err := iface.Consolidate(*main, &iface.WriteOptions{Backups: *backups})
+	return err
+}
+
+// lint prints what LintHooks finds in the hooks of every interface, and the
+// up hooks no down hook undoes. It reports whether it printed anything.
+func lint(args []string) (bool, error) {
+	fset := flag.NewFlagSet("lint", flag.ExitOnError)
+	config := fset.String("config", "/etc/network/interfaces", "interfaces file to lint")
+	root := fset.String("root", "/", "directory to look absolute commands up in, empty to skip")
+	asJSON := fset.Bool("json", false, "print the findings as JSON")
+	_ = fset.Parse(args)
+
+	ifaces, err := iface.ParseFile(*config)
+	if err != nil {
+		return false, err
+	}
+	var fsys fs.FS
+	if *root != "" {
+		fsys = os.DirFS(*root)
+	}
+	findings := ifaces.LintHooks(fsys)
+	teardowns := make(map[string][]iface.Teardown)
+	for name, netif := range ifaces {
+		missing := netif.MissingTeardowns()
+		if netif.Dual != nil {
+			missing = append(missing, netif.Dual.MissingTeardowns()...)
+		}
+		if len(missing) > 0 {
+			teardowns[name] = missing
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(struct {
+			Findings  []iface.HookFinding         `json:"findings"`
+			Teardowns map[string][]iface.Teardown `json:"missing_teardowns"`
+		}{findings, teardowns})
+		return len(findings)+len(teardowns) > 0, err
+	}
+
+	for _, f := range findings {
+		if _, err = fmt.Println(f); err != nil {
+			return false, err
+		}
+	}
+	names := make([]string, 0, len(teardowns))
+	for name := range teardowns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, t := range teardowns[name] {
+			_, err = fmt.Printf("[%s] %s %q: no down hook undoes it, add: %s %s\n", name, t.Phase, t.Hook, t.DownPhase(), t.Inverse)
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+	return len(findings)+len(teardowns) > 0, nil
+}
+
+// drift prints a drift report and returns its status, which is also the exit
+// code expected of a monitoring check.
+func drift(args []string) live.Status {
+	fset := flag.NewFlagSet("drift", flag.ExitOnError)
+	config := fset.String("config", "/etc/network/interfaces", "interfaces file to compare against")
+	resolv := fset.String("resolv", "/etc/resolv.conf", "resolv.conf to check dns-nameservers against, empty to skip")
+	addr := fset.String("addr", "", "read ip -j addr show output from this file instead of running ip, needs -route")
+	route := fset.String("route", "", "read ip -j route show output from this file instead of running ip, needs -addr")
+	asJSON := fset.Bool("json", false, "print the report as JSON")
+	_ = fset.Parse(args)
+
+	report, err := driftReport(*config, *resolv, *addr, *route)
+	if err != nil {
+		_, _ = os.Stdout.WriteString("DRIFT UNKNOWN - " + err.Error() + "\n")
+		return live.StatusUnknown
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		_, err = report.WriteTo(os.Stdout)
+	}
+	if err != nil {
+		return live.StatusUnknown
+	}
+	return report.Status
+}
+
+func driftReport(config, resolv, addr, route string) (*live.Report, error) {
+	ifaces, err := iface.ParseFile(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var snap *live.Snapshot
+	switch {
+	case addr == "" && route == "":
+		snap, err = live.Capture(context.Background())
+	default:
+		snap, err = importFiles(addr, route)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rc io.Reader
+	if resolv != "" {
+		f, err := os.Open(resolv)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		rc = f
+	}
+	return live.Compare(ifaces, snap, rc)
+}
+
+// importFiles imports a snapshot from captured ip -j output. Both files are
+// needed: without the routes every gateway would look like drift, and without
+// the addresses every auto interface would.
+func importFiles(addr, route string) (*live.Snapshot, error) {
+	if addr == "" || route == "" {
+		return nil, errors.New("-addr and -route must be given together")
+	}
+	readers := make([]io.Reader, 2)
+	for i, name := range []string{addr, route} {
+		if name == "" {
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		readers[i] = f
+	}
+	return live.Import(nil, readers[0], readers[1])
+}
@@ -0,0 +1,174 @@
+package ifupdown
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DHCP holds the options of the inet dhcp method. ifupdown hands each of them
+// to the DHCP clients that support it. The metric option, which the static
+// and tunnel methods share, is kept in NetworkInterface.Metric.
+type DHCP struct {
+	// Hostname to ask for.
+	Hostname string `json:"hostname,omitempty"`
+	// LeaseHours is the lease time to ask for, in hours (pump).
+	LeaseHours *int `json:"leasehours,omitempty"`
+	// LeaseTime is the lease time to ask for, in seconds (dhcpcd).
+	LeaseTime *int `json:"leasetime,omitempty"`
+	// Vendor is the vendor class identifier (dhcpcd).
+	Vendor string `json:"vendor,omitempty"`
+	// Client is the client identifier (dhcpcd).
+	Client string `json:"client,omitempty"`
+}
+
+func init() {
+	registerOption("hostname", dhcpOption(func(d *DHCP, v string) error {
+		d.Hostname = v
+		return nil
+	}))
+	registerOption("leasehours", dhcpOption(func(d *DHCP, v string) error {
+		return parseIntOption(&d.LeaseHours, v, 1, 1<<32/3600)
+	}))
+	registerOption("leasetime", dhcpOption(func(d *DHCP, v string) error {
+		return parseIntOption(&d.LeaseTime, v, 1, 1<<32-1)
+	}))
+	registerOption("vendor", dhcpOption(func(d *DHCP, v string) error {
+		d.Vendor = v
+		return nil
+	}))
+	registerOption("client", dhcpOption(func(d *DHCP, v string) error {
+		d.Client = v
+		return nil
+	}))
+}
+
+func dhcpOption(set func(d *DHCP, value string) error) optionParser {
+	return func(iface *NetworkInterface, value string) error {
+		if value == "" {
+			return nil
+		}
+		if iface.DHCP == nil {
+			iface.DHCP = &DHCP{}
+		}
+		return set(iface.DHCP, value)
+	}
+}
+
+// Clone returns a deep copy of d.
+func (d *DHCP) Clone() *DHCP {
+	if d == nil {
+		return nil
+	}
+	return &DHCP{
+		Hostname:   d.Hostname,
+		LeaseHours: clonePtr(d.LeaseHours),
+		LeaseTime:  clonePtr(d.LeaseTime),
+		Vendor:     d.Vendor,
+		Client:     d.Client,
+	}
+}
+
+func (d *DHCP) options() []Option {
+	if d == nil {
+		return nil
+	}
+	var opts []Option
+	if d.Hostname != "" {
+		opts = append(opts, Option{Key: "hostname", Value: d.Hostname})
+	}
+	if d.LeaseHours != nil {
+		opts = append(opts, Option{Key: "leasehours", Value: strconv.Itoa(*d.LeaseHours)})
+	}
+	if d.LeaseTime != nil {
+		opts = append(opts, Option{Key: "leasetime", Value: strconv.Itoa(*d.LeaseTime)})
+	}
+	if d.Vendor != "" {
+		opts = append(opts, Option{Key: "vendor", Value: d.Vendor})
+	}
+	if d.Client != "" {
+		opts = append(opts, Option{Key: "client", Value: d.Client})
+	}
+	return opts
+}
+
+// Validate checks the hostname and that the lease times agree.
+func (d *DHCP) Validate() error {
+	if d == nil {
+		return nil
+	}
+	if d.Hostname != "" && !validHostname(d.Hostname) {
+		return fmt.Errorf("%w: hostname %s", ErrInvalidIfaceData, d.Hostname)
+	}
+	if d.LeaseHours != nil && d.LeaseTime != nil && *d.LeaseHours*3600 != *d.LeaseTime {
+		return fmt.Errorf("%w: leasehours %d and leasetime %d disagree", ErrInvalidIfaceData, *d.LeaseHours, *d.LeaseTime)
+	}
+	return nil
+}
+
+// validHostname reports whether name can be sent as a host name. Like
+// dhclient, it takes any name of up to 253 bytes without blanks or control
+// characters, so names with underscores and the like are left to the server.
+func validHostname(name string) bool {
+	if len(name) > 253 {
+		return false
+	}
+	for _, c := range name {
+		if unicode.IsSpace(c) || unicode.IsControl(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// leaseSeconds returns the lease time d asks for in seconds, or 0.
+func (d *DHCP) leaseSeconds() int {
+	switch {
+	case d.LeaseTime != nil:
+		return *d.LeaseTime
+	case d.LeaseHours != nil:
+		return *d.LeaseHours * 3600
+	default:
+		return 0
+	}
+}
+
+// DHClientConf returns the interface block of a dhclient.conf that asks for
+// what the DHCP options of iface ask for, for hosts that run dhclient.
+func (iface *NetworkInterface) DHClientConf() (string, error) {
+	iface.mu.Lock()
+	defer iface.mu.Unlock()
+	if err := iface.validate(); err != nil {
+		return "", err
+	}
+	if iface.Config != AddressConfigDHCP {
+		return "", fmt.Errorf("[%s] %w: dhclient.conf with method %s", iface.Name, ErrWrongMethod, iface.Config)
+	}
+
+	d := iface.DHCP
+	if d == nil {
+		d = &DHCP{}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "interface %s {\n", dhclientQuote(iface.Name))
+	if d.Hostname != "" {
+		fmt.Fprintf(&b, "\tsend host-name %s;\n", dhclientQuote(d.Hostname))
+	}
+	if lease := d.leaseSeconds(); lease > 0 {
+		fmt.Fprintf(&b, "\tsend dhcp-lease-time %d;\n", lease)
+	}
+	if d.Vendor != "" {
+		fmt.Fprintf(&b, "\tsend vendor-class-identifier %s;\n", dhclientQuote(d.Vendor))
+	}
+	if d.Client != "" {
+		fmt.Fprintf(&b, "\tsend dhcp-client-identifier %s;\n", dhclientQuote(d.Client))
+	}
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+// dhclientQuote quotes s as a dhclient.conf string.
+func dhclientQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,81 @@
+package ifupdown
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNetworkInterface_DHCP(t *testing.T) {
+	ifaces := parseInterfaces(t, `iface eth0 inet dhcp
+	hostname field-07
+	leasehours 2
+	vendor acme "field"
+	client 01:52:54:00:12:34:56
+	metric 200
+`)
+	eth0 := ifaces["eth0"]
+	d := eth0.DHCP
+	if d == nil || d.Hostname != "field-07" || *d.LeaseHours != 2 || d.Vendor != `acme "field"` || d.Client != "01:52:54:00:12:34:56" {
+		t.Fatalf("dhcp = %+v", d)
+	}
+	if eth0.Metric == nil || *eth0.Metric != 200 {
+		t.Errorf("metric = %v", eth0.Metric)
+	}
+	if err := eth0.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	want := "iface eth0 inet dhcp\n" +
+		"\tmetric 200\n" +
+		"\thostname field-07\n" +
+		"\tleasehours 2\n" +
+		"\tvendor acme \"field\"\n" +
+		"\tclient 01:52:54:00:12:34:56\n"
+	if got := eth0.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+	if again := parseInterfaces(t, eth0.String())["eth0"]; again.String() != want {
+		t.Errorf("round trip = %q", again.String())
+	}
+
+	conf, err := eth0.DHClientConf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantConf := "interface \"eth0\" {\n" +
+		"\tsend host-name \"field-07\";\n" +
+		"\tsend dhcp-lease-time 7200;\n" +
+		"\tsend vendor-class-identifier \"acme \\\"field\\\"\";\n" +
+		"\tsend dhcp-client-identifier \"01:52:54:00:12:34:56\";\n" +
+		"}\n"
+	if conf != wantConf {
+		t.Errorf("DHClientConf() =\n%s\nwant\n%s", conf, wantConf)
+	}
+
+	clone := eth0.Clone()
+	*clone.DHCP.LeaseHours = 5
+	if *d.LeaseHours != 2 {
+		t.Error("Clone() shares DHCP with the original")
+	}
+}
+
+func TestDHCP_Validate(t *testing.T) {
+	for stanza, want := range map[string]error{
+		"iface eth0 inet dhcp\n\thostname two words\n":                ErrInvalidIfaceData,
+		"iface eth0 inet dhcp\n\thostname under_score\n":              nil,
+		"iface eth0 inet dhcp\n\tleasehours 1\n\tleasetime 600\n":     ErrInvalidIfaceData,
+		"iface eth0 inet dhcp\n\tleasehours 1\n\tleasetime 3600\n":    nil,
+		"iface eth0 inet static\n\taddress 10.0.0.2/24\n\tvendor x\n": nil,
+		"iface eth0 inet6 dhcp\n\thostname host\n":                    ErrWrongFamily,
+	} {
+		err := parseInterfaces(t, stanza)["eth0"].Validate()
+		if !errors.Is(err, want) || (want == nil && err != nil) {
+			t.Errorf("%q: Validate() = %v, want %v", stanza, err, want)
+		}
+	}
+
+	static := parseInterfaces(t, "iface eth0 inet static\n\taddress 10.0.0.2/24\n")["eth0"]
+	if _, err := static.DHClientConf(); !errors.Is(err, ErrWrongMethod) {
+		t.Errorf("DHClientConf() of a static interface = %v, want %v", err, ErrWrongMethod)
+	}
+}
@@ -0,0 +1,325 @@
+// Package engine brings interfaces described by ifupdown stanzas up and down
+// without the ifup and ifdown binaries.
+//
+// All kernel access goes through the Kernel interface. Netlink talks to the
+// running kernel, Fake keeps state in memory so that whole ifup and ifdown
+// sequences can be tested without root.
+//
+// The engine handles the static, manual and loopback methods: the address,
+// the default route through the gateway, with the metric option, and the
+// routes of route options. Routes added by hooks are left to the hooks.
+// Methods that need a client, such as dhcp, return ErrUnsupportedMethod.
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+
+	"git.tcp.direct/kayos/ifupdown"
+)
+
+// Route is a route the engine installs for an interface.
+type Route struct {
+	// Link is the name of the interface the route goes out of.
+	Link string
+	// Dst is the destination of the route, 0.0.0.0/0 or ::/0 for a default route.
+	Dst netip.Prefix
+	// Gateway is the next hop. The zero value means the destination is on-link.
+	Gateway netip.Addr
+	// Src is the preferred source address, if any.
+	Src netip.Addr
+	// Metric is the route priority. Zero leaves it to the kernel.
+	Metric int
+	// Table is the routing table. Zero means the main table.
+	Table int
+	// OnLink uses Gateway even if no route covers it.
+	OnLink bool
+}
+
+func (r Route) String() string {
+	s := r.Dst.String()
+	if r.Gateway.IsValid() {
+		s += " via " + r.Gateway.String()
+	}
+	s += " dev " + r.Link
+	if r.Src.IsValid() {
+		s += " src " + r.Src.String()
+	}
+	if r.Metric != 0 {
+		s += " metric " + strconv.Itoa(r.Metric)
+	}
+	if r.Table != 0 {
+		s += " table " + strconv.Itoa(r.Table)
+	}
+	if r.OnLink {
+		s += " onlink"
+	}
+	return s
+}
+
+// Kernel is the part of the kernel networking API the engine needs. Adding
+// something that already exists returns an error wrapping ErrExists, removing
+// something that does not returns one wrapping ErrNotFound.
+type Kernel interface {
+	SetHardwareAddr(link string, mac net.HardwareAddr) error
+	SetLinkUp(link string) error
+	SetLinkDown(link string) error
+	AddAddress(link string, addr netip.Prefix, broadcast netip.Addr) error
+	DelAddress(link string, addr netip.Prefix) error
+	AddRoute(route Route) error
+	DelRoute(route Route) error
+}
+
+// Engine performs the steps of ifup and ifdown against a Kernel.
+type Engine struct {
+	Kernel Kernel
+	// Hooks runs the pre/post up/down hooks. If nil, hooks are skipped.
+	Hooks ifupdown.HookRunner
+	// Tables resolves the routing tables route options name, as
+	// ifupdown.ParseRouteTables reads them. Tables given by number and the
+	// main table need no entry.
+	Tables ifupdown.RouteTables
+}
+
+// New returns an Engine that uses kernel and runs hooks through hooks.
+func New(kernel Kernel, hooks ifupdown.HookRunner) *Engine {
+	return &Engine{Kernel: kernel, Hooks: hooks}
+}
+
+// config is what the engine needs to know about an interface.
+type config struct {
+	iface     *ifupdown.NetworkInterface
+	address   netip.Prefix
+	broadcast netip.Addr
+	gateway   netip.Addr
+	metric    int
+	// routes are those of the route options.
+	routes []Route
+}
+
+func (e *Engine) newConfig(iface *ifupdown.NetworkInterface) (*config, error) {
+	if err := iface.Validate(); err != nil {
+		return nil, err
+	}
+	// work on a snapshot so other goroutines may keep using iface
+	snap := iface.Clone()
+	cfg := &config{iface: snap}
+
+	switch snap.Config {
+	case ifupdown.AddressConfigStatic, ifupdown.AddressConfigLoopback, ifupdown.AddressConfigManual:
+	default:
+		return nil, fmt.Errorf("[%s] %w: %s", snap.Name, ErrUnsupportedMethod, snap.Config)
+	}
+
+	for _, r := range snap.Routes() {
+		if r.Style != ifupdown.RouteStyleOption {
+			// the hook that adds it runs anyway
+			continue
+		}
+		route, err := e.route(snap.Name, r)
+		if err != nil {
+			return nil, err
+		}
+		cfg.routes = append(cfg.routes, route)
+	}
+	if snap.Config != ifupdown.AddressConfigStatic {
+		return cfg, nil
+	}
+
+	addr, ok := toAddr(snap.Address)
+	if !ok {
+		return nil, fmt.Errorf("[%s] %w: address %v", snap.Name, ErrInvalidInterface, snap.Address)
+	}
+	bits := addr.BitLen()
+	if snap.Netmask != nil {
+		ones, size := snap.Netmask.Size()
+		if size == 0 {
+			return nil, fmt.Errorf("[%s] %w: netmask %v", snap.Name, ErrInvalidInterface, snap.Netmask)
+		}
+		bits = ones
+	}
+	cfg.address = netip.PrefixFrom(addr, bits)
+
+	if snap.Broadcast != nil {
+		cfg.broadcast, _ = toAddr(snap.Broadcast)
+	}
+	if snap.Gateway != nil {
+		if cfg.gateway, ok = toAddr(snap.Gateway); !ok {
+			return nil, fmt.Errorf("[%s] %w: gateway %v", snap.Name, ErrInvalidInterface, snap.Gateway)
+		}
+	}
+	if snap.Metric != nil {
+		cfg.metric = *snap.Metric
+	}
+	return cfg, nil
+}
+
+// route turns a route of the interface called name into a kernel route.
+func (e *Engine) route(name string, r ifupdown.Route) (Route, error) {
+	link := r.Dev
+	if link == "" || link == "$IFACE" || link == "${IFACE}" {
+		link = name
+	}
+	var table int
+	switch id, err := strconv.ParseUint(r.Table, 10, 32); {
+	case r.Table == "", r.Table == "main":
+	case err == nil:
+		table = int(id)
+	default:
+		var ok bool
+		if table, ok = e.Tables[r.Table]; !ok {
+			return Route{}, fmt.Errorf("[%s] %w: %s", name, ifupdown.ErrUnknownTable, r.Table)
+		}
+	}
+	return Route{
+		Link:    link,
+		Dst:     r.Destination,
+		Gateway: r.Via,
+		Src:     r.Src,
+		Metric:  r.Metric,
+		Table:   table,
+		OnLink:  r.OnLink,
+	}, nil
+}
+
+// defaultRoute returns the default route through the gateway of cfg.
+func (cfg *config) defaultRoute() Route {
+	dst := netip.PrefixFrom(netip.IPv4Unspecified(), 0)
+	if cfg.gateway.Is6() {
+		dst = netip.PrefixFrom(netip.IPv6Unspecified(), 0)
+	}
+	return Route{Link: cfg.iface.Name, Dst: dst, Gateway: cfg.gateway, Metric: cfg.metric}
+}
+
+func toAddr(ip net.IP) (netip.Addr, bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	return addr.Unmap(), ok
+}
+
+func (e *Engine) hooks(ctx context.Context, iface *ifupdown.NetworkInterface, phase ifupdown.HookPhase) error {
+	if e.Hooks == nil {
+		return nil
+	}
+	_, err := iface.RunHooks(ctx, e.Hooks, phase)
+	return err
+}
+
+func step(name, what string, err error) error {
+	return fmt.Errorf("[%s] %s: %w", name, what, err)
+}
+
+// Up brings iface up the way ifup does: pre-up hooks, hardware address,
+// addresses, link up, default route, route options, post-up hooks. The
+// stanza of a dual-stack interface's other family follows.
+func (e *Engine) Up(ctx context.Context, iface *ifupdown.NetworkInterface) error {
+	cfg, err := e.newConfig(iface)
+	if err != nil {
+		return err
+	}
+	if err = e.up(ctx, cfg); err != nil || cfg.iface.Dual == nil {
+		return err
+	}
+	if cfg, err = e.newConfig(cfg.iface.Dual); err != nil {
+		return err
+	}
+	return e.up(ctx, cfg)
+}
+
+func (e *Engine) up(ctx context.Context, cfg *config) error {
+	var err error
+	name := cfg.iface.Name
+
+	if err = e.hooks(ctx, cfg.iface, ifupdown.PhasePreUp); err != nil {
+		return err
+	}
+
+	if cfg.iface.MACAddress != nil {
+		if err = e.Kernel.SetHardwareAddr(name, cfg.iface.MACAddress); err != nil {
+			return step(name, "set hardware address", err)
+		}
+	}
+
+	if cfg.address.IsValid() {
+		err = e.Kernel.AddAddress(name, cfg.address, cfg.broadcast)
+		if err != nil && !errors.Is(err, ErrExists) {
+			return step(name, "add address "+cfg.address.String(), err)
+		}
+	}
+
+	if err = e.Kernel.SetLinkUp(name); err != nil {
+		return step(name, "set link up", err)
+	}
+
+	if cfg.gateway.IsValid() {
+		route := cfg.defaultRoute()
+		if err = e.Kernel.AddRoute(route); err != nil && !errors.Is(err, ErrExists) {
+			return step(name, "add route "+route.String(), err)
+		}
+	}
+	for _, route := range cfg.routes {
+		if err = e.Kernel.AddRoute(route); err != nil && !errors.Is(err, ErrExists) {
+			return step(name, "add route "+route.String(), err)
+		}
+	}
+
+	return e.hooks(ctx, cfg.iface, ifupdown.PhasePostUp)
+}
+
+// Down takes iface down the way ifdown does: pre-down hooks, route options,
+// default route, addresses, link down, post-down hooks. The stanza of a
+// dual-stack interface's other family goes first.
+func (e *Engine) Down(ctx context.Context, iface *ifupdown.NetworkInterface) error {
+	cfg, err := e.newConfig(iface)
+	if err != nil {
+		return err
+	}
+	if cfg.iface.Dual != nil {
+		dual, err := e.newConfig(cfg.iface.Dual)
+		if err != nil {
+			return err
+		}
+		if err = e.down(ctx, dual); err != nil {
+			return err
+		}
+	}
+	return e.down(ctx, cfg)
+}
+
+func (e *Engine) down(ctx context.Context, cfg *config) error {
+	var err error
+	name := cfg.iface.Name
+
+	if err = e.hooks(ctx, cfg.iface, ifupdown.PhasePreDown); err != nil {
+		return err
+	}
+
+	for i := len(cfg.routes) - 1; i >= 0; i-- {
+		route := cfg.routes[i]
+		if err = e.Kernel.DelRoute(route); err != nil && !errors.Is(err, ErrNotFound) {
+			return step(name, "delete route "+route.String(), err)
+		}
+	}
+	if cfg.gateway.IsValid() {
+		route := cfg.defaultRoute()
+		if err = e.Kernel.DelRoute(route); err != nil && !errors.Is(err, ErrNotFound) {
+			return step(name, "delete route "+route.String(), err)
+		}
+	}
+
+	if cfg.address.IsValid() {
+		err = e.Kernel.DelAddress(name, cfg.address)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return step(name, "delete address "+cfg.address.String(), err)
+		}
+	}
+
+	if err = e.Kernel.SetLinkDown(name); err != nil {
+		return step(name, "set link down", err)
+	}
+
+	return e.hooks(ctx, cfg.iface, ifupdown.PhasePostDown)
+}
@@ -0,0 +1,224 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"git.tcp.direct/kayos/ifupdown"
+)
+
+func parse(t *testing.T, data string) *ifupdown.NetworkInterface {
+	t.Helper()
+	iface := &ifupdown.NetworkInterface{}
+	if _, err := iface.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	return iface
+}
+
+const static = `auto eth0
+iface eth0 inet static
+	address 192.168.69.5
+	netmask 255.255.255.0
+	gateway 192.168.69.1
+	hwaddress ether 02:00:00:00:00:01
+	pre-up echo pre-up
+	post-up echo post-up
+	pre-down echo pre-down
+	post-down echo post-down
+`
+
+func TestEngine_UpDown(t *testing.T) {
+	kernel := NewFake("eth0")
+	hooks := &ifupdown.DryRunner{}
+	e := New(kernel, hooks)
+	iface := parse(t, static)
+
+	if err := e.Up(context.Background(), iface); err != nil {
+		t.Fatalf("Up() = %v", err)
+	}
+
+	link, _ := kernel.Link("eth0")
+	if !link.Up {
+		t.Error("link not up")
+	}
+	if link.HardwareAddr.String() != "02:00:00:00:00:01" {
+		t.Errorf("hardware address = %s", link.HardwareAddr)
+	}
+	if !slices.Equal(link.Addresses, []netip.Prefix{netip.MustParsePrefix("192.168.69.5/24")}) {
+		t.Errorf("addresses = %v", link.Addresses)
+	}
+	want := Route{
+		Link:    "eth0",
+		Dst:     netip.MustParsePrefix("0.0.0.0/0"),
+		Gateway: netip.MustParseAddr("192.168.69.1"),
+	}
+	if routes := kernel.Routes(); !slices.Equal(routes, []Route{want}) {
+		t.Errorf("routes = %v", routes)
+	}
+
+	// bringing it up twice is fine
+	if err := e.Up(context.Background(), iface); err != nil {
+		t.Fatalf("second Up() = %v", err)
+	}
+
+	if err := e.Down(context.Background(), iface); err != nil {
+		t.Fatalf("Down() = %v", err)
+	}
+	link, _ = kernel.Link("eth0")
+	if link.Up || len(link.Addresses) != 0 || len(kernel.Routes()) != 0 {
+		t.Errorf("link after Down() = %+v, routes %v", link, kernel.Routes())
+	}
+
+	wantCalls := []string{
+		"link set eth0 address 02:00:00:00:00:01",
+		"addr add 192.168.69.5/24 dev eth0",
+		"link set eth0 up",
+		"route add 0.0.0.0/0 via 192.168.69.1 dev eth0",
+	}
+	wantCalls = append(wantCalls, wantCalls...)
+	wantCalls = append(wantCalls,
+		"route del 0.0.0.0/0 via 192.168.69.1 dev eth0",
+		"addr del 192.168.69.5/24 dev eth0",
+		"link set eth0 down",
+	)
+	if calls := kernel.Calls(); !slices.Equal(calls, wantCalls) {
+		t.Errorf("calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(wantCalls, "\n"))
+	}
+
+	var phases []string
+	for _, hook := range hooks.Hooks() {
+		phases = append(phases, hook.Command)
+	}
+	wantPhases := []string{
+		"echo pre-up", "echo post-up", "echo pre-up", "echo post-up", "echo pre-down", "echo post-down",
+	}
+	if !slices.Equal(phases, wantPhases) {
+		t.Errorf("hooks ran: %v, want %v", phases, wantPhases)
+	}
+}
+
+func TestEngine_Up_IPv6(t *testing.T) {
+	kernel := NewFake("eth0")
+	iface := ifupdown.NewNetworkInterface("eth0").
+		WithStatic().
+		WithAddressVersion(ifupdown.AddressVersion6).
+		WithAddress("2001:db8::2/64").
+		WithGateway("2001:db8::1")
+	if err := New(kernel, nil).Up(context.Background(), iface); err != nil {
+		t.Fatalf("Up() = %v", err)
+	}
+	link, _ := kernel.Link("eth0")
+	if !slices.Equal(link.Addresses, []netip.Prefix{netip.MustParsePrefix("2001:db8::2/64")}) {
+		t.Errorf("addresses = %v", link.Addresses)
+	}
+	if routes := kernel.Routes(); len(routes) != 1 || routes[0].Dst.String() != "::/0" {
+		t.Errorf("routes = %v", routes)
+	}
+}
+
+func TestEngine_Up_Errors(t *testing.T) {
+	kernel := NewFake("eth0")
+	e := New(kernel, nil)
+
+	dhcp := ifupdown.NewNetworkInterface("eth0").WithDHCP().WithAddressVersion(ifupdown.AddressVersion4)
+	if err := e.Up(context.Background(), dhcp); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Errorf("Up(dhcp) = %v, want %v", err, ErrUnsupportedMethod)
+	}
+
+	missing := ifupdown.NewNetworkInterface("eth1").WithManual().WithAddressVersion(ifupdown.AddressVersion4)
+	if err := e.Up(context.Background(), missing); !errors.Is(err, ErrNoSuchLink) {
+		t.Errorf("Up(missing) = %v, want %v", err, ErrNoSuchLink)
+	}
+
+	invalid := ifupdown.NewNetworkInterface("eth0").WithStatic()
+	if err := e.Up(context.Background(), invalid); err == nil {
+		t.Error("Up() accepted an invalid interface")
+	}
+	if calls := kernel.Calls(); len(calls) != 1 {
+		t.Errorf("kernel calls after failures = %v", calls)
+	}
+}
+
+func TestEngine_Loopback(t *testing.T) {
+	kernel := NewFake("lo")
+	lo := ifupdown.NewNetworkInterface("lo").WithLoopback().WithAddressVersion(ifupdown.AddressVersion4)
+	if err := New(kernel, nil).Up(context.Background(), lo); err != nil {
+		t.Fatalf("Up() = %v", err)
+	}
+	if link, _ := kernel.Link("lo"); !link.Up {
+		t.Error("lo not up")
+	}
+}
+
+func TestEngine_Routes(t *testing.T) {
+	kernel := NewFake("eth0")
+	e := New(kernel, nil)
+	e.Tables = ifupdown.RouteTables{"isp2": 101}
+	iface := parse(t, `iface eth0 inet static
+	address 192.168.69.5/24
+	gateway 192.168.69.1
+	metric 5
+	route 10.1.0.0/16 via 192.168.69.253 metric 2
+	route 10.2.0.0/16 dev $IFACE table isp2
+	post-up ip route add 10.3.0.0/16 via 192.168.69.254
+`)
+	if err := e.Up(context.Background(), iface); err != nil {
+		t.Fatalf("Up() = %v", err)
+	}
+	want := []Route{
+		{Link: "eth0", Dst: netip.MustParsePrefix("0.0.0.0/0"), Gateway: netip.MustParseAddr("192.168.69.1"), Metric: 5},
+		{Link: "eth0", Dst: netip.MustParsePrefix("10.1.0.0/16"), Gateway: netip.MustParseAddr("192.168.69.253"), Metric: 2},
+		{Link: "eth0", Dst: netip.MustParsePrefix("10.2.0.0/16"), Table: 101},
+	}
+	if routes := kernel.Routes(); !slices.Equal(routes, want) {
+		t.Errorf("routes = %v, want %v", routes, want)
+	}
+	if err := e.Down(context.Background(), iface); err != nil {
+		t.Fatalf("Down() = %v", err)
+	}
+	if routes := kernel.Routes(); len(routes) != 0 {
+		t.Errorf("routes after Down() = %v", routes)
+	}
+
+	e.Tables = nil
+	if err := e.Up(context.Background(), iface); !errors.Is(err, ifupdown.ErrUnknownTable) {
+		t.Errorf("Up() without the table = %v, want %v", err, ifupdown.ErrUnknownTable)
+	}
+}
+
+func TestEngine_DualStack(t *testing.T) {
+	kernel := NewFake("eth0")
+	e := New(kernel, nil)
+	p := ifupdown.NewMultiParser()
+	_, _ = p.Write([]byte(`iface eth0 inet static
+	address 192.168.69.5/24
+	gateway 192.168.69.1
+
+iface eth0 inet6 static
+	address 2001:db8::5/64
+	gateway 2001:db8::1
+`))
+	ifaces, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = e.Up(context.Background(), ifaces["eth0"]); err != nil {
+		t.Fatalf("Up() = %v", err)
+	}
+	link, _ := kernel.Link("eth0")
+	want := []netip.Prefix{netip.MustParsePrefix("192.168.69.5/24"), netip.MustParsePrefix("2001:db8::5/64")}
+	if !slices.Equal(link.Addresses, want) || len(kernel.Routes()) != 2 {
+		t.Errorf("addresses = %v, routes = %v", link.Addresses, kernel.Routes())
+	}
+	if err = e.Down(context.Background(), ifaces["eth0"]); err != nil {
+		t.Fatalf("Down() = %v", err)
+	}
+	if link, _ = kernel.Link("eth0"); link.Up || len(link.Addresses) != 0 || len(kernel.Routes()) != 0 {
+		t.Errorf("link after Down() = %+v, routes %v", link, kernel.Routes())
+	}
+}
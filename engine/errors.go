@@ -0,0 +1,12 @@
+package engine
+
+import "errors"
+
+var (
+	ErrLinkDown          = errors.New("link is down")
+	ErrNoSuchLink        = errors.New("no such link")
+	ErrExists            = errors.New("already exists")
+	ErrNotFound          = errors.New("not found")
+	ErrUnsupportedMethod = errors.New("address method not supported by engine")
+	ErrInvalidInterface  = errors.New("invalid interface")
+)
@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+)
+
+// FakeLink is the state Fake keeps for a single link.
+type FakeLink struct {
+	Name         string
+	Up           bool
+	HardwareAddr net.HardwareAddr
+	Addresses    []netip.Prefix
+}
+
+// Fake is an in-memory Kernel. It only knows the links it was created with
+// and records every call made to it, so tests can assert on the exact
+// sequence of operations.
+type Fake struct {
+	mu     sync.Mutex
+	links  map[string]*FakeLink
+	routes []Route
+	calls  []string
+}
+
+var _ Kernel = (*Fake)(nil)
+
+// NewFake returns a Fake with the given links, all down and without addresses.
+func NewFake(links ...string) *Fake {
+	f := &Fake{links: make(map[string]*FakeLink, len(links))}
+	for _, name := range links {
+		f.links[name] = &FakeLink{Name: name}
+	}
+	return f
+}
+
+// Link returns a copy of the state of the named link.
+func (f *Fake) Link(name string) (FakeLink, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l, ok := f.links[name]
+	if !ok {
+		return FakeLink{}, false
+	}
+	return FakeLink{
+		Name:         l.Name,
+		Up:           l.Up,
+		HardwareAddr: slices.Clone(l.HardwareAddr),
+		Addresses:    slices.Clone(l.Addresses),
+	}, true
+}
+
+// Routes returns the routes currently installed.
+func (f *Fake) Routes() []Route {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.routes)
+}
+
+// Calls returns a description of every call made so far, in order.
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+// link records a call and looks up the link it is for. The caller must hold mu.
+func (f *Fake) link(name, call string, args ...any) (*FakeLink, error) {
+	f.calls = append(f.calls, fmt.Sprintf(call, args...))
+	l, ok := f.links[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoSuchLink, name)
+	}
+	return l, nil
+}
+
+func (f *Fake) SetHardwareAddr(name string, mac net.HardwareAddr) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l, err := f.link(name, "link set %s address %s", name, mac)
+	if err != nil {
+		return err
+	}
+	l.HardwareAddr = slices.Clone(mac)
+	return nil
+}
+
+func (f *Fake) SetLinkUp(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l, err := f.link(name, "link set %s up", name)
+	if err != nil {
+		return err
+	}
+	l.Up = true
+	return nil
+}
+
+func (f *Fake) SetLinkDown(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l, err := f.link(name, "link set %s down", name)
+	if err != nil {
+		return err
+	}
+	l.Up = false
+	// like the kernel, drop routes that went out of the link
+	f.routes = slices.DeleteFunc(f.routes, func(r Route) bool { return r.Link == name })
+	return nil
+}
+
+func (f *Fake) AddAddress(name string, addr netip.Prefix, _ netip.Addr) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l, err := f.link(name, "addr add %s dev %s", addr, name)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(l.Addresses, addr) {
+		return fmt.Errorf("%w: %s on %s", ErrExists, addr, name)
+	}
+	l.Addresses = append(l.Addresses, addr)
+	return nil
+}
+
+func (f *Fake) DelAddress(name string, addr netip.Prefix) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l, err := f.link(name, "addr del %s dev %s", addr, name)
+	if err != nil {
+		return err
+	}
+	i := slices.Index(l.Addresses, addr)
+	if i < 0 {
+		return fmt.Errorf("%w: %s on %s", ErrNotFound, addr, name)
+	}
+	l.Addresses = slices.Delete(l.Addresses, i, i+1)
+	return nil
+}
+
+func (f *Fake) AddRoute(route Route) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l, err := f.link(route.Link, "route add %s", route)
+	if err != nil {
+		return err
+	}
+	if !l.Up {
+		return fmt.Errorf("%w: %s", ErrLinkDown, route.Link)
+	}
+	if slices.Contains(f.routes, route) {
+		return fmt.Errorf("%w: %s", ErrExists, route)
+	}
+	f.routes = append(f.routes, route)
+	return nil
+}
+
+func (f *Fake) DelRoute(route Route) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.link(route.Link, "route del %s", route); err != nil {
+		return err
+	}
+	i := slices.Index(f.routes, route)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, route)
+	}
+	f.routes = slices.Delete(f.routes, i, i+1)
+	return nil
+}
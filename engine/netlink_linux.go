@@ -0,0 +1,137 @@
+//go:build linux
+
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+)
+
+// Netlink is a Kernel that talks to the running kernel over rtnetlink.
+// It needs CAP_NET_ADMIN for everything but reading.
+type Netlink struct{}
+
+var _ Kernel = Netlink{}
+
+// kernelErr wraps err so that errors.Is works with the errors of this package.
+func kernelErr(err error, what string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, syscall.EEXIST):
+		return fmt.Errorf("%w: %s: %w", ErrExists, what, err)
+	case errors.Is(err, syscall.ESRCH), errors.Is(err, syscall.EADDRNOTAVAIL), errors.Is(err, syscall.ENOENT):
+		return fmt.Errorf("%w: %s: %w", ErrNotFound, what, err)
+	case errors.Is(err, syscall.ENETDOWN), errors.Is(err, syscall.ENETUNREACH):
+		return fmt.Errorf("%w: %s: %w", ErrLinkDown, what, err)
+	default:
+		return fmt.Errorf("%s: %w", what, err)
+	}
+}
+
+func link(name string) (netlink.Link, error) {
+	l, err := netlink.LinkByName(name)
+	if err != nil {
+		var notFound netlink.LinkNotFoundError
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNoSuchLink, name)
+		}
+		return nil, err
+	}
+	return l, nil
+}
+
+func ipNet(prefix netip.Prefix) *net.IPNet {
+	return &net.IPNet{
+		IP:   prefix.Addr().AsSlice(),
+		Mask: net.CIDRMask(prefix.Bits(), prefix.Addr().BitLen()),
+	}
+}
+
+func (Netlink) SetHardwareAddr(name string, mac net.HardwareAddr) error {
+	l, err := link(name)
+	if err != nil {
+		return err
+	}
+	return kernelErr(netlink.LinkSetHardwareAddr(l, mac), name)
+}
+
+func (Netlink) SetLinkUp(name string) error {
+	l, err := link(name)
+	if err != nil {
+		return err
+	}
+	return kernelErr(netlink.LinkSetUp(l), name)
+}
+
+func (Netlink) SetLinkDown(name string) error {
+	l, err := link(name)
+	if err != nil {
+		return err
+	}
+	return kernelErr(netlink.LinkSetDown(l), name)
+}
+
+func (Netlink) AddAddress(name string, addr netip.Prefix, broadcast netip.Addr) error {
+	l, err := link(name)
+	if err != nil {
+		return err
+	}
+	nladdr := &netlink.Addr{IPNet: ipNet(addr)}
+	if broadcast.IsValid() {
+		nladdr.Broadcast = broadcast.AsSlice()
+	}
+	return kernelErr(netlink.AddrAdd(l, nladdr), addr.String())
+}
+
+func (Netlink) DelAddress(name string, addr netip.Prefix) error {
+	l, err := link(name)
+	if err != nil {
+		return err
+	}
+	return kernelErr(netlink.AddrDel(l, &netlink.Addr{IPNet: ipNet(addr)}), addr.String())
+}
+
+func route(r Route) (*netlink.Route, error) {
+	l, err := link(r.Link)
+	if err != nil {
+		return nil, err
+	}
+	nlroute := &netlink.Route{
+		LinkIndex: l.Attrs().Index,
+		Dst:       ipNet(r.Dst),
+		Priority:  r.Metric,
+		Table:     r.Table,
+	}
+	if r.Gateway.IsValid() {
+		nlroute.Gw = r.Gateway.AsSlice()
+	}
+	if r.Src.IsValid() {
+		nlroute.Src = r.Src.AsSlice()
+	}
+	if r.OnLink {
+		nlroute.Flags = int(netlink.FLAG_ONLINK)
+	}
+	return nlroute, nil
+}
+
+func (Netlink) AddRoute(r Route) error {
+	nlroute, err := route(r)
+	if err != nil {
+		return err
+	}
+	return kernelErr(netlink.RouteAdd(nlroute), r.String())
+}
+
+func (Netlink) DelRoute(r Route) error {
+	nlroute, err := route(r)
+	if err != nil {
+		return err
+	}
+	return kernelErr(netlink.RouteDel(nlroute), r.String())
+}
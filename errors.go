@@ -9,6 +9,7 @@ var (
 	ErrInvalidGateway        = errors.New("invalid gateway")
 	ErrInvalidAddressVersion = errors.New("invalid address version")
 	ErrAddressSetWhenDHCP    = errors.New("address set when DHCP enabled")
+	ErrAddressSetWhenAuto    = errors.New("address set with auto config")
 	ErrAddressNotSetStatic   = errors.New("address not set with static config")
 	ErrMaskNotSetStatic      = errors.New("mask not set with static config")
 	ErrAdressNotLoopback     = errors.New("address must be loopback when config is loopback")
@@ -16,4 +17,21 @@ var (
 	ErrUnallocatedInterface  = errors.New("unallocated interface")
 	ErrInvalidIfaceData      = errors.New("invalid interface data provided")
 	ErrMultipleInterfaces    = errors.New("multiple interfaces in data provided")
+	ErrDuplicateInterface    = errors.New("interface defined by more than one stanza")
+	ErrSourceDepth           = errors.New("too many nested source lines")
+	ErrFragmentConflict      = errors.New("interfaces share a fragment file name")
+	ErrDefinedElsewhere      = errors.New("interface already defined in another file")
+	ErrDependencyCycle       = errors.New("interfaces depend on each other")
+	ErrHookTimeout           = errors.New("hook timed out")
+	ErrRenderMismatch        = errors.New("rendered interfaces do not parse back to the same interfaces")
+	ErrInvalidMapping        = errors.New("invalid mapping")
+	ErrMappingTimeout        = errors.New("mapping script timed out")
+	ErrUnknownOptionFamily   = errors.New("unknown option family")
+	ErrInvalidPSK            = errors.New("invalid wpa-psk")
+	ErrInvalidWireless       = errors.New("invalid wireless options")
+	ErrWrongFamily           = errors.New("not valid for the address family")
+	ErrWrongMethod           = errors.New("not valid for the method")
+	ErrMissingOption         = errors.New("required option not set")
+	ErrInvalidRouteTables    = errors.New("invalid rt_tables line")
+	ErrUnknownTable          = errors.New("routing table not in rt_tables")
 )
@@ -0,0 +1,288 @@
+package ifupdown
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Extension holds the options of one option family, such as the ipsec-*
+// options of an in-house package, for a single interface.
+type Extension interface {
+	// ParseOption applies an option of the family to the extension.
+	ParseOption(key, value string) error
+	// Validate checks the options, together with the interface they belong
+	// to. iface is locked while Validate runs, so it may read the fields of
+	// iface but must not call its methods.
+	Validate(iface *NetworkInterface) error
+	// Options renders the extension back to option lines. Parsing them
+	// again with ParseOption must give the same extension.
+	Options() []Option
+}
+
+// OptionFamily describes a family of options that some ifupdown extension,
+// like bridge-utils, vlan or ifenslave, adds to interface stanzas.
+type OptionFamily struct {
+	// Name identifies the family in NetworkInterface.Extensions and in JSON.
+	Name string
+	// Prefix claims every keyword that starts with it, such as "ipsec-".
+	Prefix string
+	// Keywords claims these keywords, in addition to those with Prefix.
+	Keywords []string
+	// Secrets lists the keywords whose values are secret, such as a key.
+	// Redacted replaces their values with Redacted, so ParseOption should
+	// take that as a value.
+	Secrets []string
+	// New returns a pointer to an empty extension for an interface. It is
+	// encoded to JSON with encoding/json, so it can implement json.Marshaler
+	// and json.Unmarshaler to pick its own representation.
+	New func() Extension
+}
+
+var families = struct {
+	sync.RWMutex
+	byName    map[string]*OptionFamily
+	byKeyword map[string]*OptionFamily
+	prefixes  []*OptionFamily
+}{
+	byName:    make(map[string]*OptionFamily),
+	byKeyword: make(map[string]*OptionFamily),
+}
+
+// topLevelKeywords are claimed by the file itself and never belong to a
+// family. allow-x stands in for every allow-<class> line.
+var topLevelKeywords = []string{
+	"iface", "auto", "allow-x", "mapping", "source", "source-directory",
+	"no-auto-down", "no-scripts", "rename",
+}
+
+// RegisterOptionFamily makes family the owner of its keywords. Like
+// database/sql.Register, it is meant to be called from init and panics when
+// the family is incomplete or claims a keyword that already has an owner.
+func RegisterOptionFamily(family OptionFamily) {
+	if family.Name == "" || family.New == nil || (family.Prefix == "" && len(family.Keywords) == 0) {
+		panic("ifupdown: option family needs a name, keywords and New")
+	}
+
+	families.Lock()
+	defer families.Unlock()
+
+	if _, dup := families.byName[family.Name]; dup {
+		panic("ifupdown: option family " + family.Name + " is registered twice")
+	}
+
+	taken := func(keyword string) bool {
+		if _, owned := optionParsers[keyword]; owned || stanzaStart(keyword) {
+			return true
+		}
+		_, owned := families.byKeyword[keyword]
+		return owned || familyByPrefix(keyword) != nil
+	}
+	for _, keyword := range family.Keywords {
+		if keyword == "" || taken(keyword) || (family.Prefix != "" && strings.HasPrefix(keyword, family.Prefix)) {
+			panic("ifupdown: option keyword " + keyword + " of " + family.Name + " is already taken")
+		}
+	}
+	if prefix := family.Prefix; prefix != "" {
+		var claimed []string
+		claimed = append(claimed, topLevelKeywords...)
+		for keyword := range optionParsers {
+			claimed = append(claimed, keyword)
+		}
+		for keyword := range families.byKeyword {
+			claimed = append(claimed, keyword)
+		}
+		// a keyword is only shadowed by a prefix it starts with, but two
+		// prefixes overlap either way round
+		for _, keyword := range claimed {
+			if strings.HasPrefix(keyword, prefix) {
+				panic("ifupdown: option prefix " + prefix + " of " + family.Name + " overlaps " + keyword)
+			}
+		}
+		for _, other := range families.prefixes {
+			if strings.HasPrefix(other.Prefix, prefix) || strings.HasPrefix(prefix, other.Prefix) {
+				panic("ifupdown: option prefix " + prefix + " of " + family.Name + " overlaps " + other.Prefix)
+			}
+		}
+	}
+
+	f := &family
+	f.Keywords = append([]string(nil), family.Keywords...)
+	f.Secrets = append([]string(nil), family.Secrets...)
+	families.byName[f.Name] = f
+	for _, keyword := range f.Keywords {
+		families.byKeyword[keyword] = f
+	}
+	if f.Prefix != "" {
+		families.prefixes = append(families.prefixes, f)
+	}
+}
+
+// familyByPrefix returns the family whose prefix keyword starts with.
+// The caller must hold the families lock.
+func familyByPrefix(keyword string) *OptionFamily {
+	for _, f := range families.prefixes {
+		if strings.HasPrefix(keyword, f.Prefix) {
+			return f
+		}
+	}
+	return nil
+}
+
+// familyOf returns the family that owns keyword, or nil.
+func familyOf(keyword string) *OptionFamily {
+	families.RLock()
+	defer families.RUnlock()
+	if f, ok := families.byKeyword[keyword]; ok {
+		return f
+	}
+	return familyByPrefix(keyword)
+}
+
+// lookupFamily returns the family called name, or nil.
+func lookupFamily(name string) *OptionFamily {
+	families.RLock()
+	defer families.RUnlock()
+	return families.byName[name]
+}
+
+// Extension returns the extension of the option family called name, or nil
+// if iface has none of its options.
+func (iface *NetworkInterface) Extension(name string) Extension {
+	iface.mu.RLock()
+	defer iface.mu.RUnlock()
+	return iface.Extensions[name]
+}
+
+// WithExtension sets the extension of the option family called name,
+// replacing the options of that family.
+func (iface *NetworkInterface) WithExtension(name string, ext Extension) *NetworkInterface {
+	iface.allocate()
+	defer iface.mu.Unlock()
+	if lookupFamily(name) == nil {
+		iface.errs = append(iface.errs, fmt.Errorf("%w: %s", ErrUnknownOptionFamily, name))
+		return iface
+	}
+	if iface.Extensions == nil {
+		iface.Extensions = make(map[string]Extension)
+	}
+	iface.Extensions[name] = ext
+	return iface
+}
+
+// parseExtension hands an option of family to the extension of iface,
+// creating it on first use. The caller must hold the write lock.
+func (iface *NetworkInterface) parseExtension(family *OptionFamily, key, value string) error {
+	ext, ok := iface.Extensions[family.Name]
+	if !ok {
+		ext = family.New()
+		if iface.Extensions == nil {
+			iface.Extensions = make(map[string]Extension)
+		}
+		iface.Extensions[family.Name] = ext
+	}
+	if err := ext.ParseOption(key, value); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidIfaceData, key, err)
+	}
+	return nil
+}
+
+// extensionNames returns the names of the extensions of iface, sorted so they
+// are validated and written in a stable order. The caller must hold at least
+// the read lock.
+func (iface *NetworkInterface) extensionNames() []string {
+	names := make([]string, 0, len(iface.Extensions))
+	for name, ext := range iface.Extensions {
+		if ext != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// cloneExtensions copies the extensions of iface by parsing what they render.
+// The caller must hold at least the read lock.
+func (iface *NetworkInterface) cloneExtensions() map[string]Extension {
+	if iface.Extensions == nil {
+		return nil
+	}
+	clone := make(map[string]Extension, len(iface.Extensions))
+	for _, name := range iface.extensionNames() {
+		family := lookupFamily(name)
+		if family == nil {
+			// set directly, not by WithExtension; share rather than lose it
+			clone[name] = iface.Extensions[name]
+			continue
+		}
+		ext := family.New()
+		for _, opt := range iface.Extensions[name].Options() {
+			// it rendered these, so it parses them
+			_ = ext.ParseOption(opt.Key, opt.Value)
+		}
+		clone[name] = ext
+	}
+	return clone
+}
+
+// redactExtensions replaces the values of the secret options of the
+// extensions of iface with Redacted. The caller must hold the write lock.
+func (iface *NetworkInterface) redactExtensions() {
+	for _, name := range iface.extensionNames() {
+		family := lookupFamily(name)
+		if family == nil || len(family.Secrets) == 0 {
+			continue
+		}
+		ext := family.New()
+		for _, opt := range iface.Extensions[name].Options() {
+			if slices.Contains(family.Secrets, opt.Key) {
+				opt.Value = Redacted
+			}
+			// an option that does not take Redacted is left out rather
+			// than kept with its secret
+			_ = ext.ParseOption(opt.Key, opt.Value)
+		}
+		iface.Extensions[name] = ext
+	}
+}
+
+// marshalExtensions encodes each extension under the name of its family.
+// The caller must hold at least the read lock.
+func (iface *NetworkInterface) marshalExtensions() (map[string]json.RawMessage, error) {
+	if len(iface.Extensions) == 0 {
+		return nil, nil
+	}
+	raw := make(map[string]json.RawMessage, len(iface.Extensions))
+	for _, name := range iface.extensionNames() {
+		data, err := json.Marshal(iface.Extensions[name])
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %w", name, err)
+		}
+		raw[name] = data
+	}
+	return raw, nil
+}
+
+// unmarshalExtensions decodes the extensions in raw into the extension of the
+// family they are named after. The caller must hold the write lock.
+func (iface *NetworkInterface) unmarshalExtensions(raw map[string]json.RawMessage) error {
+	iface.Extensions = nil
+	for name, data := range raw {
+		family := lookupFamily(name)
+		if family == nil {
+			return fmt.Errorf("%w: %s", ErrUnknownOptionFamily, name)
+		}
+		ext := family.New()
+		if err := json.Unmarshal(data, ext); err != nil {
+			return fmt.Errorf("[%s] %w", name, err)
+		}
+		if iface.Extensions == nil {
+			iface.Extensions = make(map[string]Extension)
+		}
+		iface.Extensions[name] = ext
+	}
+	return nil
+}
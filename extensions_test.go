@@ -0,0 +1,217 @@
+package ifupdown
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// ipsec stands in for the options of an in-house package.
+type ipsec struct {
+	Peer      net.IP   `json:"peer,omitempty"`
+	Proposals []string `json:"proposals,omitempty"`
+	Passive   bool     `json:"passive,omitempty"`
+	PSK       string   `json:"psk,omitempty"`
+}
+
+func (x *ipsec) ParseOption(key, value string) error {
+	switch key {
+	case "ipsec-peer":
+		if x.Peer = net.ParseIP(value); x.Peer == nil {
+			return fmt.Errorf("bad peer %q", value)
+		}
+	case "ipsec-proposal":
+		x.Proposals = append(x.Proposals, strings.Fields(value)...)
+	case "ipsec-passive":
+		x.Passive = value == "yes"
+	case "ipsec-psk":
+		x.PSK = value
+	default:
+		return fmt.Errorf("unknown option %s", key)
+	}
+	return nil
+}
+
+func (x *ipsec) Validate(iface *NetworkInterface) error {
+	if x.Peer == nil {
+		return errors.New("ipsec-peer not set")
+	}
+	if iface.Address != nil && (x.Peer.To4() == nil) != (iface.Address.To4() == nil) {
+		return errors.New("ipsec-peer and address are of different families")
+	}
+	return nil
+}
+
+func (x *ipsec) Options() []Option {
+	var opts []Option
+	if x.Peer != nil {
+		opts = append(opts, Option{Key: "ipsec-peer", Value: x.Peer.String()})
+	}
+	if len(x.Proposals) > 0 {
+		opts = append(opts, Option{Key: "ipsec-proposal", Value: strings.Join(x.Proposals, " ")})
+	}
+	if x.Passive {
+		opts = append(opts, Option{Key: "ipsec-passive", Value: "yes"})
+	}
+	if x.PSK != "" {
+		opts = append(opts, Option{Key: "ipsec-psk", Value: x.PSK})
+	}
+	return opts
+}
+
+// registerFamily registers family for the rest of the test only.
+func registerFamily(t *testing.T, family OptionFamily) {
+	t.Helper()
+	RegisterOptionFamily(family)
+	t.Cleanup(func() {
+		families.Lock()
+		defer families.Unlock()
+		f := families.byName[family.Name]
+		delete(families.byName, f.Name)
+		for _, keyword := range f.Keywords {
+			delete(families.byKeyword, keyword)
+		}
+		families.prefixes = slices.DeleteFunc(families.prefixes, func(other *OptionFamily) bool { return other == f })
+	})
+}
+
+func init() {
+	RegisterOptionFamily(OptionFamily{
+		Name:    "ipsec",
+		Prefix:  "ipsec-",
+		Secrets: []string{"ipsec-psk"},
+		New:     func() Extension { return &ipsec{} },
+	})
+}
+
+func TestOptionFamily_Parse(t *testing.T) {
+	ifaces := parseInterfaces(t, `iface tun0 inet static
+	address 10.9.0.1/30
+	ipsec-peer 192.0.2.10
+	mtu 1400
+	ipsec-proposal aes256-sha256 aes128-sha1
+	ipsec-passive yes
+`)
+	tun0 := ifaces["tun0"]
+	x, ok := tun0.Extension("ipsec").(*ipsec)
+	if !ok {
+		t.Fatalf("Extension(ipsec) = %#v", tun0.Extension("ipsec"))
+	}
+	if x.Peer.String() != "192.0.2.10" || len(x.Proposals) != 2 || !x.Passive {
+		t.Errorf("ipsec = %+v", x)
+	}
+	if slices.ContainsFunc(tun0.Options, func(opt Option) bool { return opt.Key == "ipsec-peer" }) {
+		t.Error("ipsec-peer also ended up in Options")
+	}
+	if v, _ := tun0.lookup("ipsec-peer"); v != "192.0.2.10" {
+		t.Errorf("lookup(ipsec-peer) = %q", v)
+	}
+	if err := tun0.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	want := "iface tun0 inet static\n" +
+		"\taddress 10.9.0.1\n" +
+		"\tnetmask 255.255.255.252\n" +
+		"\tmtu 1400\n" +
+		"\tipsec-peer 192.0.2.10\n" +
+		"\tipsec-proposal aes256-sha256 aes128-sha1\n" +
+		"\tipsec-passive yes\n"
+	if got := tun0.String(); !strings.Contains(got, want) {
+		t.Errorf("String() =\n%s\nwant it to contain\n%s", got, want)
+	}
+
+	clone := tun0.Clone()
+	clone.Extension("ipsec").(*ipsec).Proposals[0] = "changed"
+	if x.Proposals[0] != "aes256-sha256" {
+		t.Error("Clone() shares the extension with the original")
+	}
+}
+
+func TestOptionFamily_Redacted(t *testing.T) {
+	tun0 := parseInterfaces(t, `iface tun0 inet static
+	address 10.9.0.1/30
+	ipsec-peer 192.0.2.10
+	ipsec-psk hunter22
+`)["tun0"]
+	redacted := tun0.Redacted()
+	if x := redacted.Extension("ipsec").(*ipsec); x.PSK != Redacted || x.Peer.String() != "192.0.2.10" {
+		t.Errorf("redacted ipsec = %+v", x)
+	}
+	if out := redacted.String(); strings.Contains(out, "hunter22") || !strings.Contains(out, "\tipsec-psk REDACTED\n") {
+		t.Errorf("String() =\n%s", out)
+	}
+	if tun0.Extension("ipsec").(*ipsec).PSK != "hunter22" {
+		t.Error("Redacted() changed the original")
+	}
+}
+
+func TestOptionFamily_Errors(t *testing.T) {
+	mp := NewMultiParser()
+	_, _ = mp.Write([]byte("iface tun0 inet manual\n\tipsec-peer nowhere\n"))
+	if _, err := mp.Parse(); !errors.Is(err, ErrInvalidIfaceData) {
+		t.Errorf("Parse() = %v, want %v", err, ErrInvalidIfaceData)
+	}
+
+	tun0 := parseInterfaces(t, "iface tun0 inet static\n\taddress 10.9.0.1/30\n\tipsec-peer 2001:db8::1\n")["tun0"]
+	if err := tun0.Validate(); err == nil || !strings.Contains(err.Error(), "[ipsec] ipsec-peer and address") {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestOptionFamily_JSON(t *testing.T) {
+	iface := NewNetworkInterface("tun0").WithManual().
+		WithExtension("ipsec", &ipsec{Peer: net.ParseIP("192.0.2.10"), Passive: true})
+	data, err := json.Marshal(iface)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"extensions":{"ipsec":{"peer":"192.0.2.10","passive":true}}`) {
+		t.Errorf("MarshalJSON() = %s", data)
+	}
+
+	back := &NetworkInterface{}
+	if err = json.Unmarshal(data, back); err != nil {
+		t.Fatal(err)
+	}
+	if x, ok := back.Extension("ipsec").(*ipsec); !ok || !x.Passive || x.Peer.String() != "192.0.2.10" {
+		t.Errorf("ipsec after JSON round trip = %#v", back.Extension("ipsec"))
+	}
+
+	err = json.Unmarshal([]byte(`{"name":"tun0","extensions":{"wireguard":{}}}`), &NetworkInterface{})
+	if !errors.Is(err, ErrUnknownOptionFamily) {
+		t.Errorf("Unmarshal() = %v, want %v", err, ErrUnknownOptionFamily)
+	}
+}
+
+func TestRegisterOptionFamily_Conflicts(t *testing.T) {
+	newExt := func() Extension { return &ipsec{} }
+	for name, family := range map[string]OptionFamily{
+		"same name":        {Name: "ipsec", Prefix: "vpn-", New: newExt},
+		"core keyword":     {Name: "a", Keywords: []string{"gateway"}, New: newExt},
+		"core prefix":      {Name: "b", Prefix: "address", New: newExt},
+		"class lines":      {Name: "c", Prefix: "allow-", New: newExt},
+		"stanza keyword":   {Name: "d", Keywords: []string{"mapping"}, New: newExt},
+		"nested prefix":    {Name: "e", Prefix: "ipsec-ike-", New: newExt},
+		"enclosing prefix": {Name: "f", Prefix: "ip", New: newExt},
+		"claimed keyword":  {Name: "g", Keywords: []string{"ipsec-peer"}, New: newExt},
+		"no keywords":      {Name: "h", New: newExt},
+		"no New":           {Name: "i", Prefix: "wg-"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: RegisterOptionFamily did not panic", name)
+				}
+			}()
+			RegisterOptionFamily(family)
+		}()
+	}
+
+	// mtu- shadows no keyword, as mtu does not start with it
+	registerFamily(t, OptionFamily{Name: "mtu-probing", Prefix: "mtu-", New: newExt})
+}
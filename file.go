@@ -0,0 +1,268 @@
+package ifupdown
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// WriteOptions controls how Interfaces.WriteFile replaces a file on disk.
+type WriteOptions struct {
+	// Backups is the number of timestamped backups of the previous file to keep
+	// next to it, named <file>.<timestamp>.bak. Zero disables backups.
+	Backups int
+	// Mode is used when the target file does not exist yet. Existing files keep
+	// their mode. Defaults to 0644.
+	Mode fs.FileMode
+}
+
+// Rollback restores the file that was in place before WriteFile replaced it.
+// If there was no file before, Rollback removes the written file.
+type Rollback func() error
+
+// render validates every interface and renders the whole set.
+func (i Interfaces) render() ([]byte, error) {
+	for _, name := range i.names() {
+		if i[name] == nil {
+			return nil, fmt.Errorf("%w: %s is nil", ErrInvalidIfaceData, name)
+		}
+		if err := i[name].Validate(); err != nil {
+			return nil, fmt.Errorf("[%s] %w", name, err)
+		}
+	}
+	return []byte(i.String()), nil
+}
+
+// verify parses data back and makes sure it describes the same interfaces as
+// i: every stanza has to render the same after the round trip.
+func (i Interfaces) verify(data []byte) error {
+	mp := NewMultiParser()
+	_, _ = mp.Write(data)
+	parsed, err := mp.Parse()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRenderMismatch, err)
+	}
+	if len(parsed) != len(i) {
+		return fmt.Errorf("%w: rendered %d interfaces, parsed %d", ErrRenderMismatch, len(i), len(parsed))
+	}
+	for _, name := range i.names() {
+		iface := i[name]
+		again, ok := parsed[iface.Name]
+		if !ok {
+			return fmt.Errorf("%w: %s missing after parse", ErrRenderMismatch, iface.Name)
+		}
+		if again.String() != iface.String() {
+			return fmt.Errorf("%w: %s changed after parse", ErrRenderMismatch, iface.Name)
+		}
+	}
+	return nil
+}
+
+// WriteFile atomically replaces the file at path with the rendered interfaces.
+//
+// The data is written to a temporary file in the same directory, synced, and
+// parsed back before it is renamed over path, so a crash at any point leaves
+// either the old or the new file in place. The mode and owner of an existing
+// file are preserved, and opts.Backups copies of it are kept. The returned
+// Rollback puts the previous file back.
+func (i Interfaces) WriteFile(path string, opts *WriteOptions) (Rollback, error) {
+	data, err := i.render()
+	if err != nil {
+		return nil, err
+	}
+	if err = i.verify(data); err != nil {
+		return nil, err
+	}
+	return writeFile(path, data, opts)
+}
+
+// writeFile atomically replaces path with data, see Interfaces.WriteFile.
+func writeFile(path string, data []byte, opts *WriteOptions) (Rollback, error) {
+	if opts == nil {
+		opts = &WriteOptions{}
+	}
+
+	mode := opts.Mode
+	if mode == 0 {
+		mode = 0o644
+	}
+
+	var (
+		old     []byte
+		existed bool
+		info    fs.FileInfo
+		err     error
+	)
+
+	switch info, err = os.Stat(path); {
+	case err == nil:
+		existed = true
+		mode = info.Mode().Perm()
+		if old, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, fs.ErrNotExist):
+	default:
+		return nil, err
+	}
+
+	if existed && opts.Backups > 0 {
+		if err = backup(path, old, info, opts.Backups); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = replaceFile(path, data, mode, info); err != nil {
+		return nil, err
+	}
+
+	rollback := func() error {
+		if !existed {
+			return os.Remove(path)
+		}
+		return replaceFile(path, old, mode, info)
+	}
+
+	return rollback, nil
+}
+
+// removeFile removes path, keeping opts.Backups copies of it as writeFile
+// does. The returned Rollback puts it back.
+func removeFile(path string, opts *WriteOptions) (Rollback, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	old, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if opts != nil && opts.Backups > 0 {
+		if err = backup(path, old, info, opts.Backups); err != nil {
+			return nil, err
+		}
+	}
+	if err = os.Remove(path); err != nil {
+		return nil, err
+	}
+	if err = syncDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	rollback := func() error {
+		return replaceFile(path, old, info.Mode().Perm(), info)
+	}
+	return rollback, nil
+}
+
+// replaceFile writes data to a temporary file next to path and renames it into
+// place. If orig is not nil, the owner of orig is applied to the new file.
+func replaceFile(path string, data []byte, mode fs.FileMode, orig fs.FileInfo) (err error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if orig != nil {
+		if uid, gid, ok := fileOwner(orig); ok {
+			if err = tmp.Chown(uid, gid); err != nil {
+				return err
+			}
+		}
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// backup stores data as a timestamped copy of path and prunes old copies so
+// that at most keep remain.
+func backup(path string, data []byte, orig fs.FileInfo, keep int) error {
+	name := path + "." + time.Now().UTC().Format(backupTimeFormat) + ".bak"
+	if err := replaceFile(name, data, orig.Mode().Perm(), orig); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+
+	backups, err := Backups(path)
+	if err != nil {
+		return err
+	}
+	for len(backups) > keep {
+		if err = os.Remove(backups[0]); err != nil {
+			return fmt.Errorf("backup: %w", err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Backups returns the backups WriteFile has made of path, oldest first.
+func Backups(path string) ([]string, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, base+".") || !strings.HasSuffix(name, ".bak") {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, base+"."), ".bak")
+		if _, err = time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, name))
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+	if err = d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+	return nil
+}
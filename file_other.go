@@ -0,0 +1,9 @@
+//go:build !unix
+
+package ifupdown
+
+import "io/fs"
+
+func fileOwner(fs.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,124 @@
+package ifupdown
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testInterfaces(address string) Interfaces {
+	return Interfaces{
+		"lo": NewNetworkInterface("lo").
+			WithLoopback().
+			WithAddressVersion(AddressVersion4),
+		"eth0": NewNetworkInterface("eth0").
+			WithStatic().
+			WithAddressVersion(AddressVersion4).
+			WithAddress(address).
+			WithNetmask(24, 32),
+	}
+}
+
+func TestInterfaces_WriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "interfaces")
+
+	rollback, err := testInterfaces("10.0.0.5").WriteFile(path, &WriteOptions{Backups: 2, Mode: 0o600})
+	if err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want %v", info.Mode().Perm(), os.FileMode(0o600))
+	}
+	dat, _ := os.ReadFile(path)
+	if !strings.Contains(string(dat), "address 10.0.0.5") {
+		t.Errorf("written file missing address:\n%s", dat)
+	}
+	if strings.Index(string(dat), "iface eth0") > strings.Index(string(dat), "iface lo") {
+		t.Errorf("interfaces not written in stable order:\n%s", dat)
+	}
+
+	for _, addr := range []string{"10.0.0.6", "10.0.0.7", "10.0.0.8"} {
+		if rollback, err = testInterfaces(addr).WriteFile(path, &WriteOptions{Backups: 2}); err != nil {
+			t.Fatalf("WriteFile() = %v", err)
+		}
+	}
+
+	if info, _ = os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("mode not preserved: %v", info.Mode().Perm())
+	}
+
+	backups, err := Backups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got %d backups, want 2: %v", len(backups), backups)
+	}
+	dat, _ = os.ReadFile(backups[1])
+	if !strings.Contains(string(dat), "address 10.0.0.7") {
+		t.Errorf("newest backup should hold the previous file, got:\n%s", dat)
+	}
+
+	if err = rollback(); err != nil {
+		t.Fatalf("rollback() = %v", err)
+	}
+	dat, _ = os.ReadFile(path)
+	if !strings.Contains(string(dat), "address 10.0.0.7") {
+		t.Errorf("rollback did not restore previous file, got:\n%s", dat)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("temporary file left behind: %s", entry.Name())
+		}
+	}
+}
+
+func TestInterfaces_WriteFile_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "interfaces")
+	if err := os.WriteFile(path, []byte("auto lo\niface lo inet loopback\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := Interfaces{"eth0": NewNetworkInterface("eth0").WithAddressVersion(3)}
+	if _, err := bad.WriteFile(path, nil); err == nil {
+		t.Fatal("WriteFile() succeeded with an invalid interface")
+	}
+
+	dat, _ := os.ReadFile(path)
+	if string(dat) != "auto lo\niface lo inet loopback\n" {
+		t.Errorf("original file modified after failed write:\n%s", dat)
+	}
+}
+
+func TestInterfaces_WriteFile_RollbackNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "interfaces")
+	rollback, err := testInterfaces("10.0.0.5").WriteFile(path, nil)
+	if err != nil {
+		t.Fatalf("WriteFile() = %v", err)
+	}
+	if err = rollback(); err != nil {
+		t.Fatalf("rollback() = %v", err)
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("rollback of a new file should remove it, stat = %v", err)
+	}
+}
+
+func TestInterfaces_verify(t *testing.T) {
+	ifaces := testInterfaces("10.0.0.5")
+	if err := ifaces.verify([]byte(ifaces.String())); err != nil {
+		t.Fatalf("verify() of the rendered interfaces = %v", err)
+	}
+	if err := ifaces.verify([]byte(testInterfaces("10.0.0.6").String())); !errors.Is(err, ErrRenderMismatch) {
+		t.Errorf("verify() with another address = %v, want %v", err, ErrRenderMismatch)
+	}
+}
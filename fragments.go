@@ -0,0 +1,288 @@
+package ifupdown
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FragmentName returns the file name WriteFragments uses for the interface
+// called name. Characters that run-parts(8) naming rules do not allow, such as
+// the dot in VLAN interface names, are replaced with underscores.
+func FragmentName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// rollbacks undoes a series of writes, newest first.
+type rollbacks []Rollback
+
+func (rbs rollbacks) rollback() error {
+	var errs []error
+	for n := len(rbs) - 1; n >= 0; n-- {
+		errs = append(errs, rbs[n]())
+	}
+	return errors.Join(errs...)
+}
+
+// WriteFragments writes every interface into its own file in dir, named by
+// FragmentName, and replaces main with a file that pulls them in through a
+// source-directory line. A relative dir is taken relative to the directory of
+// main, as source-directory does.
+//
+// Whatever main holds besides interface stanzas, class lines and the source
+// lines that point into dir, such as mapping stanzas, no-auto-down or rename
+// lines and source lines for other files, is kept ahead of the
+// source-directory line.
+//
+// Files that main still sources afterwards are checked for the interfaces in
+// i. A file in dir that holds nothing but some of them is removed, as its
+// stanzas move into the fragments. Any other file that defines one of them
+// the same way keeps it and no fragment is written for it; one that defines
+// it differently makes WriteFragments fail with ErrDefinedElsewhere before
+// anything is written.
+//
+// Every file is replaced the same way as with WriteFile. If any write fails,
+// the ones that already happened are rolled back.
+func (i Interfaces) WriteFragments(main, dir string, opts *WriteOptions) (Rollback, error) {
+	owners := make(map[string]string, len(i))
+	for _, name := range i.names() {
+		frag := FragmentName(name)
+		if prev, ok := owners[frag]; ok {
+			return nil, fmt.Errorf("%w: %s and %s both map to %s", ErrFragmentConflict, prev, name, frag)
+		}
+		owners[frag] = name
+	}
+
+	absMain, err := filepath.Abs(main)
+	if err != nil {
+		return nil, err
+	}
+	fragDir := dir
+	if !filepath.IsAbs(dir) {
+		fragDir = filepath.Join(filepath.Dir(absMain), dir)
+	}
+	fragDir = filepath.Clean(fragDir)
+
+	// source lines that point into dir are replaced by source-directory,
+	// the others stay.
+	outside := func(fields []string) bool {
+		target := fields[1]
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(absMain), target)
+		}
+		target = filepath.Clean(target)
+		return target != fragDir && !strings.HasPrefix(target, fragDir+string(filepath.Separator))
+	}
+
+	kept, err := os.ReadFile(main)
+	switch {
+	case err == nil:
+		kept = keptLines(kept, outside)
+	case errors.Is(err, fs.ErrNotExist):
+	default:
+		return nil, err
+	}
+
+	remove, skip, err := i.fragmentOverlap(absMain, kept, fragDir, owners)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(fragDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	var done rollbacks
+	for _, file := range remove {
+		rb, err := removeFile(file, opts)
+		if err != nil {
+			return nil, errors.Join(err, done.rollback())
+		}
+		done = append(done, rb)
+	}
+	for _, name := range i.names() {
+		if skip[name] {
+			continue
+		}
+		rb, err := Interfaces{name: i[name]}.WriteFile(filepath.Join(fragDir, FragmentName(name)), opts)
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("[%s] %w", name, err), done.rollback())
+		}
+		done = append(done, rb)
+	}
+
+	rb, err := writeFile(main, append(kept, "source-directory "+dir+"\n"...), opts)
+	if err != nil {
+		return nil, errors.Join(err, done.rollback())
+	}
+	done = append(done, rb)
+
+	return done.rollback, nil
+}
+
+// fragmentOverlap looks through the files main still sources once it is split
+// into fragDir, the kept source lines and the files already in fragDir, for
+// the interfaces in i. It returns the files in fragDir to remove and the
+// interfaces that need no fragment of their own, see WriteFragments.
+func (i Interfaces) fragmentOverlap(main string, kept []byte, fragDir string, owners map[string]string) ([]string, map[string]bool, error) {
+	fsys := os.DirFS("/")
+	dirPath := fsPath(fragDir)
+
+	files, err := runParts(fsys, dirPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, err
+	}
+	lines := newLineReader(bytes.NewReader(kept))
+	for {
+		line, ok := lines.next()
+		if !ok {
+			break
+		}
+		fields := tokens(line)
+		if len(fields) < 2 || (fields[0] != "source" && fields[0] != "source-directory") {
+			continue
+		}
+		more, err := sourced(fsys, fsPath(main), fields)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, err
+		}
+		files = append(files, more...)
+	}
+
+	var (
+		remove []string
+		skip   = make(map[string]bool)
+		seen   = make(map[string]bool)
+	)
+	for _, file := range files {
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+
+		data, err := ExpandSources(fsys, file)
+		if err != nil {
+			return nil, nil, err
+		}
+		mp := NewMultiParser()
+		_, _ = mp.Write(data)
+		defined, err := mp.Parse()
+		if err != nil {
+			return nil, nil, fmt.Errorf("/%s: %w", file, err)
+		}
+		other := len(keptLines(data, nil)) > 0
+		for _, name := range defined.names() {
+			if _, ok := i[name]; !ok {
+				other = true
+			}
+		}
+
+		inDir := path.Dir(file) == dirPath
+		if _, target := owners[path.Base(file)]; inDir && target {
+			if other {
+				return nil, nil, fmt.Errorf("%w: /%s holds more than a fragment would", ErrDefinedElsewhere, file)
+			}
+			continue
+		}
+		if inDir && !other && len(defined) > 0 {
+			remove = append(remove, "/"+file)
+			continue
+		}
+		for _, name := range defined.names() {
+			iface, ok := i[name]
+			if !ok {
+				continue
+			}
+			if defined[name].String() != iface.String() {
+				return nil, nil, fmt.Errorf("[%s] %w: /%s", name, ErrDefinedElsewhere, file)
+			}
+			skip[name] = true
+		}
+	}
+	return remove, skip, lines.err()
+}
+
+// keptLines returns the stanzas of the interfaces file data that
+// WriteFragments keeps in the main file: all but iface stanzas, auto and
+// allow-* lines, which move into the fragments, and the source and
+// source-directory lines keepSource does not accept. A nil keepSource drops
+// them all. Comments are dropped. A blank line follows every stanza.
+func keptLines(data []byte, keepSource func(fields []string) bool) []byte {
+	var (
+		buf  bytes.Buffer
+		keep bool
+	)
+	lines := newLineReader(bytes.NewReader(data))
+	for {
+		line, ok := lines.next()
+		if !ok {
+			break
+		}
+		fields := tokens(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if stanzaStart(fields[0]) {
+			if keep {
+				buf.WriteByte('\n')
+			}
+			switch {
+			case fields[0] == "source", fields[0] == "source-directory":
+				keep = keepSource != nil && len(fields) > 1 && keepSource(fields)
+			case isClassLine(fields[0]), fields[0] == "iface":
+				keep = false
+			default:
+				keep = true
+			}
+		}
+		if keep {
+			buf.WriteString(strings.TrimRight(line, " \t") + "\n")
+		}
+	}
+	if keep {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// Consolidate reads main along with every file it sources and replaces it
+// with a single file holding all of the interfaces, without source lines.
+// Everything else, such as mapping stanzas and no-auto-down, no-scripts or
+// rename lines, is kept ahead of the interfaces. The fragments themselves are
+// not removed.
+func Consolidate(main string, opts *WriteOptions) (Rollback, error) {
+	abs, err := filepath.Abs(main)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ExpandSources(os.DirFS("/"), abs)
+	if err != nil {
+		return nil, err
+	}
+	mp := NewMultiParser()
+	_, _ = mp.Write(data)
+	ifaces, err := mp.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, err := ifaces.render()
+	if err != nil {
+		return nil, err
+	}
+	if err = ifaces.verify(rendered); err != nil {
+		return nil, err
+	}
+	return writeFile(main, append(keptLines(data, nil), rendered...), opts)
+}
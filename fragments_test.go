@@ -0,0 +1,238 @@
+package ifupdown
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFragmentName(t *testing.T) {
+	for name, want := range map[string]string{
+		"eth0":      "eth0",
+		"eth0.100":  "eth0_100",
+		"br-lan":    "br-lan",
+		"eth0:1":    "eth0_1",
+		"wlan0_ap1": "wlan0_ap1",
+	} {
+		if got := FragmentName(name); got != want {
+			t.Errorf("FragmentName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestInterfaces_WriteFragments(t *testing.T) {
+	root := t.TempDir()
+	main := filepath.Join(root, "interfaces")
+	dir := filepath.Join(root, "interfaces.d")
+
+	if _, err := testInterfaces("10.0.0.5").WriteFile(main, nil); err != nil {
+		t.Fatal(err)
+	}
+	ifaces, err := ParseFile(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ifaces.WriteFragments(main, dir, &WriteOptions{Backups: 1}); err != nil {
+		t.Fatalf("WriteFragments() = %v", err)
+	}
+
+	dat, _ := os.ReadFile(main)
+	if string(dat) != "source-directory "+dir+"\n" {
+		t.Errorf("main file = %q", dat)
+	}
+	for _, name := range []string{"lo", "eth0"} {
+		frag, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("missing fragment for %s: %v", name, err)
+		}
+		if !strings.Contains(string(frag), "iface "+name+" ") {
+			t.Errorf("fragment %s = %q", name, frag)
+		}
+	}
+
+	split, err := ParseFile(main)
+	if err != nil {
+		t.Fatalf("ParseFile() = %v", err)
+	}
+	if len(split) != 2 {
+		t.Fatalf("got %d interfaces after split, want 2", len(split))
+	}
+
+	if _, err = Consolidate(main, nil); err != nil {
+		t.Fatalf("Consolidate() = %v", err)
+	}
+	dat, _ = os.ReadFile(main)
+	if strings.Contains(string(dat), "source") || !strings.Contains(string(dat), "address 10.0.0.5") {
+		t.Errorf("consolidated file = %q", dat)
+	}
+}
+
+func TestInterfaces_WriteFragments_Conflict(t *testing.T) {
+	root := t.TempDir()
+	ifaces := Interfaces{
+		"eth0.100": NewNetworkInterface("eth0.100").WithManual().WithAddressVersion(AddressVersion4),
+		"eth0_100": NewNetworkInterface("eth0_100").WithManual().WithAddressVersion(AddressVersion4),
+	}
+	_, err := ifaces.WriteFragments(filepath.Join(root, "interfaces"), filepath.Join(root, "interfaces.d"), nil)
+	if !errors.Is(err, ErrFragmentConflict) {
+		t.Errorf("WriteFragments() = %v, want %v", err, ErrFragmentConflict)
+	}
+}
+
+func TestConsolidate_Mappings(t *testing.T) {
+	root := t.TempDir()
+	main := filepath.Join(root, "interfaces")
+	dir := filepath.Join(root, "interfaces.d")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(main, []byte("source-directory "+dir+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "eth0"), []byte(mappingFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Consolidate(main, nil); err != nil {
+		t.Fatalf("Consolidate() = %v", err)
+	}
+	dat, _ := os.ReadFile(main)
+	if !strings.HasPrefix(string(dat), "mapping eth0 wlan*\n") || !strings.Contains(string(dat), "mapping eth0-home\n") {
+		t.Errorf("consolidated file lost its mappings: %q", dat)
+	}
+}
+
+const splitFile = `no-auto-down eth1
+mapping eth1
+	script /usr/local/sbin/map-scheme
+	map HOME eth1-home
+
+source old.d/*
+auto eth0
+iface eth0 inet static
+	address 10.0.0.5/24
+
+# set up elsewhere
+rename eth9=eth1
+`
+
+func TestInterfaces_WriteFragments_Main(t *testing.T) {
+	root := t.TempDir()
+	main := filepath.Join(root, "interfaces")
+	if err := os.WriteFile(main, []byte(splitFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "old.d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "old.d", "wlan0"), []byte("iface wlan0 inet manual\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ifaces := Interfaces{"eth0": NewNetworkInterface("eth0").WithStatic().WithVersion(AddressVersion4).
+		WithAddress("10.0.0.5").WithNetmask(24, 32)}
+
+	// a relative dir is relative to main, wherever the process runs
+	if _, err := ifaces.WriteFragments(main, "interfaces.d", nil); err != nil {
+		t.Fatalf("WriteFragments() = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "interfaces.d", "eth0")); err != nil {
+		t.Fatalf("fragment not next to main: %v", err)
+	}
+	want := "no-auto-down eth1\n\n" +
+		"mapping eth1\n\tscript /usr/local/sbin/map-scheme\n\tmap HOME eth1-home\n\n" +
+		"source old.d/*\n\n" +
+		"rename eth9=eth1\n\n" +
+		"source-directory interfaces.d\n"
+	if dat, _ := os.ReadFile(main); string(dat) != want {
+		t.Errorf("main file =\n%s\nwant\n%s", dat, want)
+	}
+
+	if _, err := Consolidate(main, nil); err != nil {
+		t.Fatalf("Consolidate() after split = %v", err)
+	}
+	dat, _ := os.ReadFile(main)
+	want = "no-auto-down eth1\n\n" +
+		"mapping eth1\n\tscript /usr/local/sbin/map-scheme\n\tmap HOME eth1-home\n\n" +
+		"rename eth9=eth1\n\n"
+	if !strings.HasPrefix(string(dat), want) || !strings.Contains(string(dat), "address 10.0.0.5") ||
+		!strings.Contains(string(dat), "iface wlan0 inet manual") || strings.Contains(string(dat), "source") {
+		t.Errorf("consolidated file = %q", dat)
+	}
+}
+
+func TestInterfaces_WriteFragments_Existing(t *testing.T) {
+	root := t.TempDir()
+	main := filepath.Join(root, "interfaces")
+	dir := filepath.Join(root, "interfaces.d")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(main, []byte("source-directory "+dir+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := "auto eth0\niface eth0 inet static\n\taddress 10.0.0.5/24\n"
+	if err := os.WriteFile(filepath.Join(dir, "10-eth0"), []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ifaces, err := ParseFile(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rb, err := ifaces.WriteFragments(main, dir, nil)
+	if err != nil {
+		t.Fatalf("WriteFragments() = %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "10-eth0")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("old fragment still there: %v", err)
+	}
+	split, err := ParseFile(main)
+	if err != nil {
+		t.Fatalf("ParseFile() after split = %v", err)
+	}
+	if split["eth0"].String() != ifaces["eth0"].String() {
+		t.Errorf("eth0 after split = %q", split["eth0"])
+	}
+
+	if err = rb(); err != nil {
+		t.Fatalf("rollback = %v", err)
+	}
+	if dat, _ := os.ReadFile(filepath.Join(dir, "10-eth0")); string(dat) != old {
+		t.Errorf("old fragment after rollback = %q", dat)
+	}
+}
+
+func TestInterfaces_WriteFragments_DefinedElsewhere(t *testing.T) {
+	root := t.TempDir()
+	main := filepath.Join(root, "interfaces")
+	if err := os.WriteFile(main, []byte("source other\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(root, "other")
+	if err := os.WriteFile(other, []byte("iface eth0 inet manual\n\nmapping eth1\n\tscript /bin/true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	same, err := ParseFile(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = same.WriteFragments(main, "interfaces.d", nil); err != nil {
+		t.Fatalf("WriteFragments() = %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(root, "interfaces.d", "eth0")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("fragment written for eth0 defined in %s: %v", other, err)
+	}
+	if dat, _ := os.ReadFile(main); string(dat) != "source other\n\nsource-directory interfaces.d\n" {
+		t.Errorf("main file = %q", dat)
+	}
+
+	differs := Interfaces{"eth0": NewNetworkInterface("eth0").WithDHCP().WithAddressVersion(AddressVersion4)}
+	if _, err = differs.WriteFragments(main, "interfaces.d", nil); !errors.Is(err, ErrDefinedElsewhere) {
+		t.Errorf("WriteFragments() = %v, want %v", err, ErrDefinedElsewhere)
+	}
+}
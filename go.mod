@@ -1,3 +1,13 @@
 module git.tcp.direct/kayos/ifupdown
 
 go 1.21.4
+
+require (
+	github.com/vishvananda/netlink v1.3.1
+	mvdan.cc/sh/v3 v3.7.0
+)
+
+require (
+	github.com/vishvananda/netns v0.0.5 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+)
@@ -0,0 +1,255 @@
+package ifupdown
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Graph holds the dependencies between a set of interfaces: bridges on their
+// ports, bonds on their slaves, VLANs on their raw devices.
+type Graph struct {
+	// deps maps an interface to the configured interfaces it depends on.
+	deps map[string][]string
+	// missing maps an interface to dependencies that have no stanza.
+	missing map[string][]string
+}
+
+// listOption splits a list valued option such as bridge-ports, dropping the
+// special values that do not name an interface.
+func listOption(value string) []string {
+	var names []string
+	for _, name := range strings.Fields(value) {
+		switch name {
+		case "none", "all":
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// effectiveOption looks key up on iface and, failing that, on the stanzas it
+// inherits from.
+func (i Interfaces) effectiveOption(iface *NetworkInterface, key string) (string, bool) {
+	seen := make(map[string]bool)
+	for iface != nil && !seen[iface.Name] {
+		seen[iface.Name] = true
+		iface.mu.RLock()
+		value, ok := iface.lookup(key)
+		parent := iface.Inherits
+		iface.mu.RUnlock()
+		if ok {
+			return value, true
+		}
+		iface = i[parent]
+	}
+	return "", false
+}
+
+// dependencies returns the names of the interfaces iface needs before it can
+// be brought up, configured or not. slaves maps bonds to the interfaces that
+// name them in bond-master.
+func (i Interfaces) dependencies(name string, slaves map[string][]string) []string {
+	iface := i[name]
+	var deps []string
+
+	for _, key := range []string{"bridge-ports", "bond-slaves"} {
+		if value, ok := i.effectiveOption(iface, key); ok {
+			deps = append(deps, listOption(value)...)
+		}
+	}
+
+	if raw, ok := i.effectiveOption(iface, "vlan-raw-device"); ok {
+		deps = append(deps, raw)
+	} else if dot := strings.LastIndexByte(name, '.'); dot > 0 {
+		// eth0.100 style VLAN
+		deps = append(deps, name[:dot])
+	}
+
+	// slaves that point at their bond with bond-master
+	deps = append(deps, slaves[name]...)
+
+	slices.Sort(deps)
+	return slices.Compact(deps)
+}
+
+// Graph builds the dependency graph of i. It fails with ErrDependencyCycle if
+// interfaces depend on each other, as no order could bring them up.
+func (i Interfaces) Graph() (*Graph, error) {
+	g := &Graph{
+		deps:    make(map[string][]string, len(i)),
+		missing: make(map[string][]string),
+	}
+	slaves := make(map[string][]string)
+	for _, name := range i.names() {
+		if master, ok := i.effectiveOption(i[name], "bond-master"); ok {
+			slaves[master] = append(slaves[master], name)
+		}
+	}
+
+	for _, name := range i.names() {
+		if i[name] == nil {
+			continue
+		}
+		g.deps[name] = []string{}
+		for _, dep := range i.dependencies(name, slaves) {
+			if dep == name {
+				return nil, fmt.Errorf("%w: %s -> %s", ErrDependencyCycle, name, name)
+			}
+			if _, ok := i[dep]; ok {
+				g.deps[name] = append(g.deps[name], dep)
+				continue
+			}
+			g.missing[name] = append(g.missing[name], dep)
+		}
+	}
+	if cycle := g.cycle(); cycle != nil {
+		return nil, fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(cycle, " -> "))
+	}
+	return g, nil
+}
+
+// cycle returns a dependency cycle in g, or nil if there is none.
+func (g *Graph) cycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(g.deps))
+	var stack []string
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			start := slices.Index(stack, name)
+			return append(slices.Clone(stack[start:]), name)
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, dep := range g.deps[name] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+		return nil
+	}
+
+	for _, name := range g.sorted() {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+func (g *Graph) sorted() []string {
+	names := make([]string, 0, len(g.deps))
+	for name := range g.deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Dependencies returns the configured interfaces name directly depends on.
+func (g *Graph) Dependencies(name string) []string {
+	return slices.Clone(g.deps[name])
+}
+
+// Missing returns, for every interface that has them, the dependencies that
+// have no stanza of their own. That is normal for physical bridge ports or
+// bond slaves, but usually a typo for anything else.
+func (g *Graph) Missing() map[string][]string {
+	missing := make(map[string][]string, len(g.missing))
+	for name, deps := range g.missing {
+		missing[name] = slices.Clone(deps)
+	}
+	return missing
+}
+
+// closure returns names and everything they transitively depend on. With no
+// names it returns every interface in g.
+func (g *Graph) closure(names []string) map[string]bool {
+	set := make(map[string]bool, len(g.deps))
+	if len(names) == 0 {
+		for name := range g.deps {
+			set[name] = true
+		}
+		return set
+	}
+	var add func(name string)
+	add = func(name string) {
+		if _, ok := g.deps[name]; !ok || set[name] {
+			return
+		}
+		set[name] = true
+		for _, dep := range g.deps[name] {
+			add(dep)
+		}
+	}
+	for _, name := range names {
+		add(name)
+	}
+	return set
+}
+
+// Levels groups names, and everything they depend on, into levels that can
+// be brought up one after the other. The interfaces within a level do not
+// depend on each other and can be brought up in parallel. With no names,
+// every interface in g is included.
+func (g *Graph) Levels(names ...string) [][]string {
+	set := g.closure(names)
+	level := make(map[string]int, len(set))
+
+	var depth func(name string) int
+	depth = func(name string) int {
+		if d, ok := level[name]; ok {
+			return d
+		}
+		d := 0
+		for _, dep := range g.deps[name] {
+			d = max(d, depth(dep)+1)
+		}
+		level[name] = d
+		return d
+	}
+
+	var levels [][]string
+	for _, name := range g.sorted() {
+		if !set[name] {
+			continue
+		}
+		d := depth(name)
+		for len(levels) <= d {
+			levels = append(levels, nil)
+		}
+		levels[d] = append(levels[d], name)
+	}
+	return levels
+}
+
+// UpOrder returns names and their dependencies in an order that brings every
+// interface up after the ones it depends on, like ifup -a would.
+func (g *Graph) UpOrder(names ...string) []string {
+	var order []string
+	for _, level := range g.Levels(names...) {
+		order = append(order, level...)
+	}
+	return order
+}
+
+// DownOrder is the reverse of UpOrder, taking interfaces down before the
+// ones they depend on.
+func (g *Graph) DownOrder(names ...string) []string {
+	order := g.UpOrder(names...)
+	slices.Reverse(order)
+	return order
+}
@@ -0,0 +1,157 @@
+package ifupdown
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func parseInterfaces(t testing.TB, data string) Interfaces {
+	t.Helper()
+	mp := NewMultiParser()
+	_, _ = mp.Write([]byte(data))
+	ifaces, err := mp.Parse()
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	return ifaces
+}
+
+func TestInterfaces_Graph(t *testing.T) {
+	ifaces := parseInterfaces(t, `auto lo
+iface lo inet loopback
+
+iface eth0 inet manual
+
+iface eth1 inet manual
+	bond-master bond0
+
+iface eth2 inet manual
+
+iface bond0 inet manual
+	bond-slaves eth2
+
+iface br-template inet manual
+	bridge-ports bond0 eth9
+
+auto br0
+iface br0 inet static inherits br-template
+	address 10.0.0.1/24
+
+iface vlan100 inet manual
+	vlan-raw-device br0
+
+iface eth0.200 inet manual
+`)
+
+	g, err := ifaces.Graph()
+	if err != nil {
+		t.Fatalf("Graph() = %v", err)
+	}
+
+	for name, want := range map[string][]string{
+		"bond0":    {"eth1", "eth2"},
+		"br0":      {"bond0"},
+		"vlan100":  {"br0"},
+		"eth0.200": {"eth0"},
+		"lo":       {},
+	} {
+		if got := g.Dependencies(name); !slices.Equal(got, want) {
+			t.Errorf("Dependencies(%s) = %v, want %v", name, got, want)
+		}
+	}
+
+	if missing := g.Missing(); !slices.Equal(missing["br0"], []string{"eth9"}) {
+		t.Errorf("Missing() = %v", missing)
+	}
+
+	levels := g.Levels("vlan100")
+	want := [][]string{{"eth1", "eth2"}, {"bond0"}, {"br0"}, {"vlan100"}}
+	if len(levels) != len(want) {
+		t.Fatalf("Levels() = %v, want %v", levels, want)
+	}
+	for i := range want {
+		if !slices.Equal(levels[i], want[i]) {
+			t.Errorf("Levels()[%d] = %v, want %v", i, levels[i], want[i])
+		}
+	}
+
+	up := g.UpOrder()
+	if len(up) != len(ifaces) {
+		t.Errorf("UpOrder() = %v, want all %d interfaces", up, len(ifaces))
+	}
+	pos := func(order []string, name string) int { return slices.Index(order, name) }
+	for _, name := range up {
+		for _, dep := range g.Dependencies(name) {
+			if pos(up, dep) > pos(up, name) {
+				t.Errorf("UpOrder() brings %s up before its dependency %s: %v", name, dep, up)
+			}
+		}
+	}
+	down := g.DownOrder("br0")
+	if !slices.Equal(down, []string{"br0", "bond0", "eth2", "eth1"}) {
+		t.Errorf("DownOrder(br0) = %v", down)
+	}
+}
+
+func TestInterfaces_Graph_Cycle(t *testing.T) {
+	ifaces := parseInterfaces(t, `iface br0 inet manual
+	bridge-ports br1
+
+iface br1 inet manual
+	bridge-ports bond0
+
+iface bond0 inet manual
+	bond-slaves br0
+`)
+	_, err := ifaces.Graph()
+	if !errors.Is(err, ErrDependencyCycle) {
+		t.Fatalf("Graph() = %v, want %v", err, ErrDependencyCycle)
+	}
+	if !strings.Contains(err.Error(), "bond0 -> br0 -> br1 -> bond0") {
+		t.Errorf("cycle not described: %v", err)
+	}
+}
+
+// bridgeOptions keeps bridge-* options the way a bridge-utils package might.
+type bridgeOptions struct{ opts []Option }
+
+func (b *bridgeOptions) ParseOption(key, value string) error {
+	b.opts = append(b.opts, Option{Key: key, Value: value})
+	return nil
+}
+
+func (b *bridgeOptions) Validate(*NetworkInterface) error { return nil }
+
+func (b *bridgeOptions) Options() []Option { return b.opts }
+
+func TestInterfaces_Graph_Extension(t *testing.T) {
+	registerFamily(t, OptionFamily{Name: "bridge", Prefix: "bridge-", New: func() Extension { return &bridgeOptions{} }})
+	ifaces := parseInterfaces(t, `iface eth0 inet manual
+
+iface br-template inet manual
+	bridge-ports eth0 eth1
+
+iface br0 inet manual inherits br-template
+	bridge-stp off
+
+iface br1 inet manual
+	bridge-ports br0
+`)
+	if ifaces["br1"].Extension("bridge") == nil {
+		t.Fatal("bridge-ports did not go to the bridge family")
+	}
+	g, err := ifaces.Graph()
+	if err != nil {
+		t.Fatalf("Graph() = %v", err)
+	}
+	for name, want := range map[string][]string{"br0": {"eth0"}, "br1": {"br0"}} {
+		if got := g.Dependencies(name); !slices.Equal(got, want) {
+			t.Errorf("Dependencies(%s) = %v, want %v", name, got, want)
+		}
+	}
+	if missing := g.Missing(); !slices.Equal(missing["br0"], []string{"eth1"}) {
+		t.Errorf("Missing() = %v", missing)
+	}
+}
@@ -0,0 +1,224 @@
+package ifupdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HookPhase is the point in bringing an interface up or down at which a hook runs.
+type HookPhase string
+
+const (
+	PhasePreUp    HookPhase = "pre-up"
+	PhasePostUp   HookPhase = "post-up"
+	PhasePreDown  HookPhase = "pre-down"
+	PhasePostDown HookPhase = "post-down"
+)
+
+// Mode returns the value ifupdown sets MODE to during the phase.
+func (phase HookPhase) Mode() string {
+	switch phase {
+	case PhasePreUp, PhasePostUp:
+		return "start"
+	case PhasePreDown, PhasePostDown:
+		return "stop"
+	default:
+		return ""
+	}
+}
+
+// hookPath is the PATH ifupdown runs hooks with.
+const hookPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// Hook is a single command about to be run for an interface.
+type Hook struct {
+	// Interface is the name of the interface the hook belongs to.
+	Interface string
+	// Phase the hook runs in.
+	Phase HookPhase
+	// Command is passed to the shell as is.
+	Command string
+	// Env is the environment the command runs with, in os.Environ form.
+	Env []string
+}
+
+// HookResult is the outcome of running a Hook.
+type HookResult struct {
+	Hook Hook
+	// Output is everything the command wrote to stdout and stderr.
+	Output []byte
+	// Duration is how long the command ran for.
+	Duration time.Duration
+	// Err is the error the runner returned, if any.
+	Err error
+}
+
+// HookRunner executes hook commands.
+type HookRunner interface {
+	// Run executes hook and returns its combined output.
+	Run(ctx context.Context, hook Hook) ([]byte, error)
+}
+
+// ShellRunner runs hooks with "/bin/sh -c", the way ifupdown does.
+type ShellRunner struct {
+	// Shell is the shell to run commands with. Defaults to /bin/sh.
+	Shell string
+	// Timeout limits how long each hook may run. Zero means no limit
+	// other than the context passed to Run.
+	Timeout time.Duration
+}
+
+func (r *ShellRunner) Run(ctx context.Context, hook Hook) ([]byte, error) {
+	shell := r.Shell
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, shell, "-c", hook.Command)
+	cmd.Env = hook.Env
+	// don't wait forever on children that keep the output pipe open
+	cmd.WaitDelay = time.Second
+
+	out, err := cmd.CombinedOutput()
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w: %w", ErrHookTimeout, err)
+	}
+	return out, err
+}
+
+// DryRunner records the hooks it is asked to run instead of running them.
+type DryRunner struct {
+	mu    sync.Mutex
+	hooks []Hook
+}
+
+func (r *DryRunner) Run(_ context.Context, hook Hook) ([]byte, error) {
+	r.mu.Lock()
+	r.hooks = append(r.hooks, hook)
+	r.mu.Unlock()
+	return nil, nil
+}
+
+// Hooks returns every hook recorded so far, in the order Run saw them.
+func (r *DryRunner) Hooks() []Hook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Hook(nil), r.hooks...)
+}
+
+// phase returns the hooks registered for phase.
+func (hooks Hooks) phase(phase HookPhase) []string {
+	switch phase {
+	case PhasePreUp:
+		return hooks.PreUp
+	case PhasePostUp:
+		return hooks.PostUp
+	case PhasePreDown:
+		return hooks.PreDown
+	case PhasePostDown:
+		return hooks.PostDown
+	default:
+		return nil
+	}
+}
+
+// hookKeyword reports whether keyword declares a hook. ifupdown keeps
+// these out of the IF_* environment.
+func hookKeyword(keyword string) bool {
+	switch keyword {
+	case "pre-up", "up", "post-up", "down", "pre-down", "post-down":
+		return true
+	default:
+		return false
+	}
+}
+
+// envName turns an option keyword into the name of its IF_* variable.
+func envName(keyword string) string {
+	var b strings.Builder
+	b.WriteString("IF_")
+	for _, r := range keyword {
+		switch {
+		case r >= 'a' && r <= 'z':
+			b.WriteRune(r - 'a' + 'A')
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '-', r == '_':
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// HookEnv returns the environment ifupdown gives hooks of iface in phase:
+// IFACE, LOGICAL, ADDRFAM, METHOD, MODE, PHASE, PATH and an IF_* variable
+// for every option of the stanza.
+func (iface *NetworkInterface) HookEnv(phase HookPhase) []string {
+	iface.mu.RLock()
+	defer iface.mu.RUnlock()
+	return iface.hookEnv(phase)
+}
+
+func (iface *NetworkInterface) hookEnv(phase HookPhase) []string {
+	env := []string{
+		"IFACE=" + iface.Name,
+		"LOGICAL=" + iface.Name,
+		"ADDRFAM=" + iface.Version.String(),
+		"METHOD=" + iface.Config.String(),
+		"MODE=" + phase.Mode(),
+		"PHASE=" + string(phase),
+		"VERBOSITY=0",
+		"PATH=" + hookPath,
+	}
+
+	// later options win, like they do in ifupdown
+	index := make(map[string]int)
+	for _, opt := range iface.options() {
+		if hookKeyword(opt.Key) {
+			continue
+		}
+		name := envName(opt.Key)
+		if i, ok := index[name]; ok {
+			env[i] = name + "=" + opt.Value
+			continue
+		}
+		index[name] = len(env)
+		env = append(env, name+"="+opt.Value)
+	}
+
+	return env
+}
+
+// RunHooks runs the hooks of iface for phase, in order, through runner.
+// Like ifupdown, it stops at the first hook that fails. The results of every
+// hook that ran are returned along with the error of the failed one.
+func (iface *NetworkInterface) RunHooks(ctx context.Context, runner HookRunner, phase HookPhase) ([]HookResult, error) {
+	iface.mu.RLock()
+	commands := slices.Clone(iface.Hooks.phase(phase))
+	env := iface.hookEnv(phase)
+	name := iface.Name
+	iface.mu.RUnlock()
+
+	results := make([]HookResult, 0, len(commands))
+	for _, command := range commands {
+		hook := Hook{Interface: name, Phase: phase, Command: command, Env: env}
+		start := time.Now()
+		out, err := runner.Run(ctx, hook)
+		results = append(results, HookResult{Hook: hook, Output: out, Duration: time.Since(start), Err: err})
+		if err != nil {
+			return results, fmt.Errorf("[%s] %s %q: %w", name, phase, command, err)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,115 @@
+package ifupdown
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func hookTestInterface(t *testing.T) *NetworkInterface {
+	t.Helper()
+	iface := &NetworkInterface{}
+	_, err := iface.Write([]byte(`auto eth0
+iface eth0 inet static
+	address 10.0.0.5
+	netmask 255.255.255.0
+	vlan-raw-device eth9
+	pre-up echo "$PHASE $MODE $IFACE"
+	up echo "$IF_ADDRESS $IF_VLAN_RAW_DEVICE $ADDRFAM $METHOD"
+	post-up false
+	post-up echo unreachable
+	down echo bye
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return iface
+}
+
+func TestNetworkInterface_HookEnv(t *testing.T) {
+	env := hookTestInterface(t).HookEnv(PhasePreDown)
+	for _, want := range []string{
+		"IFACE=eth0", "LOGICAL=eth0", "ADDRFAM=inet", "METHOD=static", "MODE=stop", "PHASE=pre-down",
+		"IF_ADDRESS=10.0.0.5", "IF_NETMASK=255.255.255.0", "IF_VLAN_RAW_DEVICE=eth9",
+	} {
+		if !slices.Contains(env, want) {
+			t.Errorf("HookEnv() missing %s: %v", want, env)
+		}
+	}
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "IF_PRE_UP=") || strings.HasPrefix(kv, "IF_POST_UP=") {
+			t.Errorf("HookEnv() includes hook option %s", kv)
+		}
+	}
+}
+
+func TestNetworkInterface_RunHooks(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	iface := hookTestInterface(t)
+	runner := &ShellRunner{}
+	ctx := context.Background()
+
+	results, err := iface.RunHooks(ctx, runner, PhasePreUp)
+	if err != nil {
+		t.Fatalf("RunHooks() = %v", err)
+	}
+	if len(results) != 1 || string(results[0].Output) != "pre-up start eth0\n" {
+		t.Errorf("RunHooks() = %+v", results)
+	}
+
+	results, err = iface.RunHooks(ctx, runner, PhasePostUp)
+	if err == nil {
+		t.Fatal("RunHooks() did not report the failing hook")
+	}
+	if len(results) != 2 {
+		t.Fatalf("RunHooks() ran %d hooks, want it to stop after the failing one", len(results))
+	}
+	if string(results[0].Output) != "10.0.0.5 eth9 inet static\n" {
+		t.Errorf("up hook output = %q", results[0].Output)
+	}
+}
+
+func TestShellRunner_Timeout(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	runner := &ShellRunner{Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	_, err := runner.Run(context.Background(), Hook{Command: "sleep 5"})
+	if !errors.Is(err, ErrHookTimeout) {
+		t.Errorf("Run() = %v, want %v", err, ErrHookTimeout)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("Run() took %v, timeout not enforced", time.Since(start))
+	}
+}
+
+func TestDryRunner(t *testing.T) {
+	iface := hookTestInterface(t)
+	runner := &DryRunner{}
+	for _, phase := range []HookPhase{PhasePreUp, PhasePostUp, PhasePreDown, PhasePostDown} {
+		if _, err := iface.RunHooks(context.Background(), runner, phase); err != nil {
+			t.Fatalf("RunHooks(%s) = %v", phase, err)
+		}
+	}
+	var got []string
+	for _, hook := range runner.Hooks() {
+		got = append(got, string(hook.Phase)+": "+hook.Command)
+	}
+	want := []string{
+		`pre-up: echo "$PHASE $MODE $IFACE"`,
+		`post-up: echo "$IF_ADDRESS $IF_VLAN_RAW_DEVICE $ADDRFAM $METHOD"`,
+		`post-up: false`,
+		`post-up: echo unreachable`,
+		`pre-down: echo bye`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("DryRunner recorded:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -1,40 +1,77 @@
 package ifupdown
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"sync"
 )
 
 type Interfaces map[string]*NetworkInterface
 
-func (i Interfaces) buf() *bytes.Buffer {
+// names returns the keys of i in a stable order so that rendered output
+// does not depend on map iteration order.
+func (i Interfaces) names() []string {
+	names := make([]string, 0, len(i))
+	for name := range i {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reader renders the interfaces as WriteTo does and returns a reader of the
+// result. Interfaces is a map and cannot keep track of how much of it has
+// been read, so it is not an io.Reader itself.
+func (i Interfaces) Reader() (io.Reader, error) {
 	buf := &bytes.Buffer{}
-	for _, iface := range i {
-		err := iface.write(func(s string) { _, _ = buf.Write([]byte(s)) })
-		if err != nil && !errors.Is(err, io.EOF) {
-			panic(err)
-		}
-		_, _ = buf.Write([]byte("\n"))
+	if _, err := i.WriteTo(buf); err != nil {
+		return nil, err
 	}
-	return buf
+	return buf, nil
 }
 
-func (i Interfaces) Read(p []byte) (int, error) {
-	buf := i.buf()
-	defer pools.Buffers.Put(buf)
-	return buf.Read(p)
+// WriteTo renders every interface into w, in the same order as String. The
+// auto and allow-* lines come first, one per class, naming all interfaces in
+// the class, as in "auto lo eth0 eth1". It stops at the first interface that
+// does not validate.
+func (i Interfaces) WriteTo(w io.Writer) (int64, error) {
+	return i.writeTo(w, true)
+}
+
+func (i Interfaces) writeTo(w io.Writer, validate bool) (int64, error) {
+	cw := &countingWriter{w: w}
+	if lines := i.classLines(); len(lines) > 0 {
+		if _, err := io.WriteString(cw, strings.Join(lines, "")+"\n"); err != nil {
+			return cw.n, err
+		}
+	}
+	enc := NewEncoder(cw)
+	enc.stanzas = true
+	enc.unchecked = !validate
+	for _, name := range i.names() {
+		if i[name] == nil {
+			continue
+		}
+		if err := enc.Encode(i[name]); err != nil {
+			_ = enc.Flush()
+			return cw.n, err
+		}
+	}
+	err := enc.Flush()
+	return cw.n, err
 }
 
+// String renders every interface like WriteTo, but without validating them,
+// so that interfaces that do not validate are shown as they are.
 func (i Interfaces) String() string {
-	buf := i.buf()
-	defer pools.Buffers.Put(buf)
-	return buf.String()
+	var b strings.Builder
+	_, _ = i.writeTo(&b, false)
+	return b.String()
 }
 
 func (i Interfaces) UnmarshalJSON(data []byte) error {
@@ -43,8 +80,12 @@ func (i Interfaces) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	for name, iface := range ifaces {
-		iface.Name = name
-		iface.allocated = true
+		if iface != nil {
+			iface.Name = name
+			if iface.Dual != nil && iface.Dual.Name == "" {
+				iface.Dual.Name = name
+			}
+		}
 		i[name] = iface
 	}
 
@@ -53,9 +94,11 @@ func (i Interfaces) UnmarshalJSON(data []byte) error {
 
 type MultiParser struct {
 	Interfaces map[string]*NetworkInterface
-	Errs       []error
-	buf        []byte
-	mu         *sync.Mutex
+	// Mappings holds the mapping stanzas, in the order they appeared.
+	Mappings Mappings
+	Errs     []error
+	buf      []byte
+	mu       *sync.Mutex
 }
 
 func NewMultiParser() *MultiParser {
@@ -74,65 +117,46 @@ func (p *MultiParser) Write(data []byte) (int, error) {
 	return len(data), nil
 }
 
+// Parse decodes everything written to p so far. For large inputs, a Decoder
+// avoids holding the whole input in memory. The second stanza of a dual-stack
+// interface becomes the Dual of the first; any further stanza of an interface
+// is reported as ErrDuplicateInterface and left out.
 func (p *MultiParser) Parse() (Interfaces, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	scanner := bufio.NewScanner(strings.NewReader(string(p.buf)))
 
-	index := 0
-	currentIfaceName := ""
-
-	buf := pools.Buffers.Get()
-	defer pools.Buffers.Put(buf)
-
-	flush := func(name string) (*NetworkInterface, bool) {
-		if len(buf.Bytes()) == 0 {
-			return nil, false
+	dec := NewDecoder(bytes.NewReader(p.buf))
+	for {
+		iface, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			break
 		}
-		defer buf.Reset()
-		newIface := NewNetworkInterface(name)
-		if _, err := buf.WriteTo(newIface); err != nil {
+		if err != nil {
 			p.Errs = append(p.Errs, err)
-			return nil, false
+			continue
 		}
-		p.Interfaces[newIface.Name] = newIface
-		return newIface, true
-	}
-
-	w := func(s string) {
-		_, _ = buf.WriteString(s)
-		_ = buf.WriteByte('\n')
-	}
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		upForChange := currentIfaceName == "" ||
-			(currentIfaceName != "" && !strings.Contains(line, currentIfaceName)) ||
-			index == 0
-		startDetected := len(strings.Fields(line)) > 1 &&
-			(strings.HasPrefix(line, "auto") ||
-				strings.HasPrefix(line, "allow-") ||
-				strings.HasPrefix(line, "iface"))
-
+		prev, ok := p.Interfaces[iface.Name]
 		switch {
-		case line == "", strings.HasPrefix(line, "#"):
-			continue
-		case upForChange && startDetected:
-			newName := strings.Fields(line)[1]
-			if ifa, ok := flush(newName); ok {
-				currentIfaceName = ifa.Name
-				index++
-			}
-			w(line)
-			continue
+		case !ok || prev == nil:
+			p.Interfaces[iface.Name] = iface
+		case prev.Dual == nil && prev.Version != iface.Version:
+			prev.Update(func(prev *NetworkInterface) { prev.Dual = iface })
 		default:
-			w(line)
+			p.Errs = append(p.Errs, fmt.Errorf("[%s] %w: second %s stanza",
+				iface.Name, ErrDuplicateInterface, iface.Version))
 		}
 	}
+	p.Mappings = dec.Mappings()
 
-	if len(buf.Bytes()) > 0 {
-		flush("unknown")
+	// auto and allow-* lines may come after the stanzas they name
+	for name, iface := range p.Interfaces {
+		for _, class := range dec.Classes(name) {
+			if iface != nil && !iface.InClass(class) {
+				iface.WithAllow(class)
+			}
+		}
 	}
+
 	var multiErr error
 	for _, err := range p.Errs {
 		switch {
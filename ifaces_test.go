@@ -1,7 +1,10 @@
 package ifupdown
 
 import (
+	"errors"
+	"io"
 	"testing"
+	"testing/iotest"
 )
 
 func TestParse_SimpleValidData(t *testing.T) {
@@ -50,3 +53,32 @@ iface eth0 inet dhcp
 }
 
 // Add more test functions here to check other aspects and edge cases.
+
+func TestInterfaces_String_Invalid(t *testing.T) {
+	mp := NewMultiParser()
+	_, _ = mp.Write([]byte("iface eth0 inet static\n\tdns-nameservers 1.1.1.1\n"))
+	ifaces, err := mp.Parse()
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	if got, want := ifaces.String(), "iface eth0 inet static\n\tdns-nameservers 1.1.1.1\n\n"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if _, err = ifaces.WriteTo(io.Discard); !errors.Is(err, ErrAddressNotSetStatic) {
+		t.Errorf("WriteTo() = %v, want %v", err, ErrAddressNotSetStatic)
+	}
+	if _, err = ifaces.Reader(); !errors.Is(err, ErrAddressNotSetStatic) {
+		t.Errorf("Reader() = %v, want %v", err, ErrAddressNotSetStatic)
+	}
+}
+
+func TestInterfaces_Reader(t *testing.T) {
+	ifaces := testInterfaces("10.0.0.5")
+	r, err := ifaces.Reader()
+	if err != nil {
+		t.Fatalf("Reader() = %v", err)
+	}
+	if err = iotest.TestReader(r, []byte(ifaces.String())); err != nil {
+		t.Error(err)
+	}
+}
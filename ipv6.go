@@ -0,0 +1,235 @@
+package ifupdown
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// IPv6 holds the options ifupdown only knows for inet6 stanzas. Unset
+// options are nil, so ifupdown picks its default.
+type IPv6 struct {
+	// AcceptRA is accept_ra: 0 ignores router advertisements, 1 accepts them
+	// unless forwarding, 2 accepts them even when forwarding.
+	AcceptRA *int `json:"accept_ra,omitempty"`
+	// Autoconf is autoconf: whether to configure addresses from router
+	// advertisements.
+	Autoconf *bool `json:"autoconf,omitempty"`
+	// Privext is privext: 0 disables privacy extensions, 1 enables them, 2
+	// also prefers the temporary addresses.
+	Privext *int `json:"privext,omitempty"`
+	// DADAttempts is dad-attempts: how many times to wait for duplicate
+	// address detection, 0 to skip it.
+	DADAttempts *int `json:"dad_attempts,omitempty"`
+	// DADInterval is dad-interval: seconds between duplicate address
+	// detection checks.
+	DADInterval *float64 `json:"dad_interval,omitempty"`
+	// PreferredLifetime is preferred-lifetime: seconds the address is
+	// preferred, 0 to deprecate it.
+	PreferredLifetime *int `json:"preferred_lifetime,omitempty"`
+}
+
+// scopes are the values of the scope option, which inet and inet6 share.
+var scopes = []string{"global", "site", "link", "host"}
+
+func init() {
+	registerOption("accept_ra", ipv6Option(func(o *IPv6, v string) error {
+		return parseIntOption(&o.AcceptRA, v, 0, 2)
+	}))
+	registerOption("autoconf", ipv6Option(func(o *IPv6, v string) error {
+		var on *int
+		err := parseIntOption(&on, v, 0, 1)
+		if err == nil {
+			o.Autoconf = new(bool)
+			*o.Autoconf = *on == 1
+		}
+		return err
+	}))
+	registerOption("privext", ipv6Option(func(o *IPv6, v string) error {
+		return parseIntOption(&o.Privext, v, 0, 2)
+	}))
+	registerOption("dad-attempts", ipv6Option(func(o *IPv6, v string) error {
+		return parseIntOption(&o.DADAttempts, v, 0, 1<<16)
+	}))
+	registerOption("dad-interval", ipv6Option(func(o *IPv6, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return fmt.Errorf("%w: %s", ErrInvalidIfaceData, v)
+		}
+		o.DADInterval = &f
+		return nil
+	}))
+	registerOption("preferred-lifetime", ipv6Option(func(o *IPv6, v string) error {
+		return parseIntOption(&o.PreferredLifetime, v, 0, 1<<32-1)
+	}))
+	registerOption("scope", func(iface *NetworkInterface, value string) error {
+		if value == "" {
+			return nil
+		}
+		iface.Scope = value
+		return nil
+	})
+}
+
+// ipv6Option returns a parser that hands the value to set, creating the IPv6
+// options of the interface on first use.
+func ipv6Option(set func(o *IPv6, value string) error) optionParser {
+	return func(iface *NetworkInterface, value string) error {
+		if value == "" {
+			return nil
+		}
+		if iface.IPv6 == nil {
+			iface.IPv6 = &IPv6{}
+		}
+		return set(iface.IPv6, value)
+	}
+}
+
+// parseIntOption parses value into *dst, which must be between min and max.
+func parseIntOption(dst **int, value string, min, max int) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return fmt.Errorf("%w: %s", ErrInvalidIfaceData, value)
+	}
+	*dst = &n
+	return nil
+}
+
+// Clone returns a deep copy of o.
+func (o *IPv6) Clone() *IPv6 {
+	if o == nil {
+		return nil
+	}
+	return &IPv6{
+		AcceptRA:          clonePtr(o.AcceptRA),
+		Autoconf:          clonePtr(o.Autoconf),
+		Privext:           clonePtr(o.Privext),
+		DADAttempts:       clonePtr(o.DADAttempts),
+		DADInterval:       clonePtr(o.DADInterval),
+		PreferredLifetime: clonePtr(o.PreferredLifetime),
+	}
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+// options lists the IPv6 options in the order they are written.
+func (o *IPv6) options() []Option {
+	if o == nil {
+		return nil
+	}
+	var opts []Option
+	addInt := func(key string, n *int) {
+		if n != nil {
+			opts = append(opts, Option{Key: key, Value: strconv.Itoa(*n)})
+		}
+	}
+	addInt("accept_ra", o.AcceptRA)
+	if o.Autoconf != nil {
+		autoconf := "0"
+		if *o.Autoconf {
+			autoconf = "1"
+		}
+		opts = append(opts, Option{Key: "autoconf", Value: autoconf})
+	}
+	addInt("privext", o.Privext)
+	addInt("dad-attempts", o.DADAttempts)
+	if o.DADInterval != nil {
+		opts = append(opts, Option{Key: "dad-interval", Value: strconv.FormatFloat(*o.DADInterval, 'f', -1, 64)})
+	}
+	addInt("preferred-lifetime", o.PreferredLifetime)
+	return opts
+}
+
+// set lists the keywords of the options set in o.
+func (o *IPv6) set() []string {
+	var keys []string
+	for _, opt := range o.options() {
+		keys = append(keys, opt.Key)
+	}
+	return keys
+}
+
+// validateFamily checks that the method, addresses and options of iface
+// belong to its address family. The caller must hold the write lock.
+func (iface *NetworkInterface) validateFamily() []error {
+	var errs []error
+	wrong := func(what string) {
+		errs = append(errs, fmt.Errorf("[%s] %w: %s in %s stanza", iface.Name, ErrWrongFamily, what, iface.Version))
+	}
+
+	switch iface.Version {
+	case AddressVersion4:
+		if keys := iface.IPv6.set(); len(keys) > 0 {
+			wrong(strings.Join(keys, ", "))
+		}
+		if iface.Address != nil && iface.Address.To4() == nil {
+			wrong("address " + iface.Address.String())
+		}
+		if iface.Gateway != nil && iface.Gateway.To4() == nil {
+			wrong("gateway " + iface.Gateway.String())
+		}
+	case AddressVersion6:
+		if iface.Broadcast != nil {
+			wrong("broadcast")
+		}
+		var dhcp []string
+		for _, opt := range iface.DHCP.options() {
+			dhcp = append(dhcp, opt.Key)
+		}
+		if len(dhcp) > 0 {
+			wrong(strings.Join(dhcp, ", "))
+		}
+		if iface.Address != nil && iface.Address.To4() != nil {
+			wrong("address " + iface.Address.String())
+		}
+		if iface.Gateway != nil && iface.Gateway.To4() != nil {
+			wrong("gateway " + iface.Gateway.String())
+		}
+	}
+
+	if iface.Scope != "" && !slices.Contains(scopes, iface.Scope) {
+		errs = append(errs, fmt.Errorf("[%s] %w: scope %s", iface.Name, ErrInvalidIfaceData, iface.Scope))
+	}
+	return errs
+}
+
+// WithIPv6 sets the inet6 options of the interface.
+func (iface *NetworkInterface) WithIPv6(o *IPv6) *NetworkInterface {
+	iface.allocate()
+	iface.IPv6 = o.Clone()
+	iface.mu.Unlock()
+	return iface
+}
+
+// validateDual checks that Dual, if iface has one, is a valid stanza of the
+// same interface in the other address family. The caller must hold the write
+// lock.
+func (iface *NetworkInterface) validateDual() []error {
+	dual := iface.Dual
+	if dual == nil {
+		return nil
+	}
+	if dual == iface {
+		return []error{fmt.Errorf("[%s] %w: stanza is its own dual", iface.Name, ErrDuplicateInterface)}
+	}
+	dual.mu.Lock()
+	defer dual.mu.Unlock()
+	var errs []error
+	switch {
+	case dual.Name != iface.Name:
+		errs = append(errs, fmt.Errorf("[%s] %w: dual stanza of %s", iface.Name, ErrInvalidIfaceData, dual.Name))
+	case dual.Version == iface.Version || dual.Dual != nil:
+		errs = append(errs, fmt.Errorf("[%s] %w: second %s stanza", iface.Name, ErrDuplicateInterface, dual.Version))
+	}
+	if err := dual.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
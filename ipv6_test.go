@@ -0,0 +1,151 @@
+package ifupdown
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNetworkInterface_IPv6Options(t *testing.T) {
+	ifaces := parseInterfaces(t, `iface eth0 inet6 auto
+	accept_ra 2
+	privext 2
+
+iface eth1 inet6 static
+	address 2001:db8::10/64
+	gateway 2001:db8::1
+	autoconf 0
+	dad-attempts 3
+	dad-interval 0.5
+	preferred-lifetime 0
+	scope global
+`)
+
+	eth0 := ifaces["eth0"]
+	if eth0.Config != AddressConfigAuto || eth0.Config.String() != "auto" {
+		t.Errorf("eth0 config = %s", eth0.Config)
+	}
+	if o := eth0.IPv6; o == nil || *o.AcceptRA != 2 || *o.Privext != 2 || o.Autoconf != nil {
+		t.Errorf("eth0 ipv6 = %+v", o)
+	}
+
+	eth1 := ifaces["eth1"]
+	o := eth1.IPv6
+	if o == nil || *o.Autoconf || *o.DADAttempts != 3 || *o.DADInterval != 0.5 || *o.PreferredLifetime != 0 {
+		t.Fatalf("eth1 ipv6 = %+v", o)
+	}
+	if eth1.Scope != "global" {
+		t.Errorf("scope = %q", eth1.Scope)
+	}
+	for _, name := range []string{"eth0", "eth1"} {
+		if err := ifaces[name].Validate(); err != nil {
+			t.Errorf("[%s] Validate() = %v", name, err)
+		}
+	}
+
+	want := "\tautoconf 0\n\tdad-attempts 3\n\tdad-interval 0.5\n\tpreferred-lifetime 0\n\tscope global\n"
+	if got := eth1.String(); !strings.Contains(got, want) {
+		t.Errorf("String() =\n%s\nwant it to contain\n%s", got, want)
+	}
+	if got := eth0.String(); !strings.Contains(got, "iface eth0 inet6 auto\n\taccept_ra 2\n\tprivext 2\n") {
+		t.Errorf("String() = %q", got)
+	}
+
+	clone := eth1.Clone()
+	*clone.IPv6.DADAttempts = 9
+	if *o.DADAttempts != 3 {
+		t.Error("Clone() shares IPv6 with the original")
+	}
+}
+
+func TestNetworkInterface_IPv6Options_Invalid(t *testing.T) {
+	for _, opt := range []string{"accept_ra 3", "autoconf yes", "privext -1", "dad-interval soon"} {
+		mp := NewMultiParser()
+		_, _ = mp.Write([]byte("iface eth0 inet6 auto\n\t" + opt + "\n"))
+		if _, err := mp.Parse(); !errors.Is(err, ErrInvalidIfaceData) {
+			t.Errorf("%s: Parse() = %v, want %v", opt, err, ErrInvalidIfaceData)
+		}
+	}
+}
+
+func TestNetworkInterface_ValidateFamily(t *testing.T) {
+	for stanza, want := range map[string]error{
+		"iface eth0 inet auto\n":                                                      ErrWrongFamily,
+		"iface eth0 inet dhcp\n\taccept_ra 1\n":                                       ErrWrongFamily,
+		"iface eth0 inet static\n\taddress 10.0.0.2/24\n\tdad-attempts 0\n":           ErrWrongFamily,
+		"iface eth0 inet static\n\taddress 2001:db8::2/64\n":                          ErrWrongFamily,
+		"iface eth0 inet6 static\n\taddress 2001:db8::2/64\n\tbroadcast 10.0.0.255\n": ErrWrongFamily,
+		"iface eth0 inet6 static\n\taddress 2001:db8::2/64\n\tgateway 10.0.0.1\n":     ErrWrongFamily,
+		"iface eth0 inet6 auto\n\taddress 2001:db8::2\n":                              ErrAddressSetWhenAuto,
+		"iface eth0 inet static\n\taddress 10.0.0.2/24\n\tscope galaxy\n":             ErrInvalidIfaceData,
+		"iface eth0 inet static\n\taddress 10.0.0.2/24\n\tscope link\n":               nil,
+	} {
+		err := parseInterfaces(t, stanza)["eth0"].Validate()
+		if !errors.Is(err, want) || (want == nil && err != nil) {
+			t.Errorf("%q: Validate() = %v, want %v", stanza, err, want)
+		}
+	}
+}
+
+const dualStackFile = `auto eth0
+
+iface eth0 inet static
+	address 10.0.0.2
+	netmask 255.255.255.0
+	gateway 10.0.0.1
+
+iface eth0 inet6 static
+	address 2001:db8::2
+	gateway 2001:db8::1
+
+`
+
+func TestMultiParser_DualStack(t *testing.T) {
+	ifaces := parseInterfaces(t, dualStackFile)
+	eth0 := ifaces["eth0"]
+	if eth0 == nil || eth0.Version != AddressVersion4 || eth0.Address.String() != "10.0.0.2" {
+		t.Fatalf("eth0 = %+v, want the inet stanza", eth0)
+	}
+	if dual := eth0.Dual; dual == nil || dual.Version != AddressVersion6 || dual.Address.String() != "2001:db8::2" {
+		t.Fatalf("Dual = %+v, want the inet6 stanza", eth0.Dual)
+	}
+	if err := eth0.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := ifaces.WriteTo(buf); err != nil {
+		t.Fatalf("WriteTo() = %v", err)
+	}
+	if buf.String() != dualStackFile {
+		t.Errorf("WriteTo() =\n%s\nwant\n%s", buf, dualStackFile)
+	}
+	if again := parseInterfaces(t, buf.String()); again["eth0"].String() != eth0.String() {
+		t.Errorf("round trip changed eth0:\n%s", again["eth0"])
+	}
+
+	data, err := json.Marshal(ifaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := make(Interfaces)
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["eth0"].String() != eth0.String() {
+		t.Errorf("JSON round trip changed eth0:\n%s", decoded["eth0"])
+	}
+
+	mp := NewMultiParser()
+	_, _ = mp.Write([]byte(dualStackFile + "iface eth0 inet6 manual\n"))
+	if _, err = mp.Parse(); !errors.Is(err, ErrDuplicateInterface) {
+		t.Errorf("Parse() with a third stanza = %v, want %v", err, ErrDuplicateInterface)
+	}
+	eth0.Dual.Version = AddressVersion4
+	eth0.Dual.Update(func(*NetworkInterface) {})
+	if err = eth0.Validate(); !errors.Is(err, ErrDuplicateInterface) {
+		t.Errorf("Validate() with two inet stanzas = %v, want %v", err, ErrDuplicateInterface)
+	}
+}
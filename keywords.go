@@ -0,0 +1,162 @@
+package ifupdown
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// optionParser applies the value of an option, everything after its keyword,
+// to iface. The caller holds the write lock.
+type optionParser func(iface *NetworkInterface, value string) error
+
+// optionParsers maps the keywords of the options with a field of their own
+// to their parser. Keywords are matched exactly; anything not in the table
+// ends up in Options.
+var optionParsers = make(map[string]optionParser)
+
+// registerOption makes parse the owner of keyword. Every keyword has a
+// single owner, so registering one twice, or registering a keyword that
+// starts a stanza, panics.
+func registerOption(keyword string, parse optionParser) {
+	if _, taken := optionParsers[keyword]; taken || stanzaStart(keyword) || keyword == "" {
+		panic("ifupdown: option keyword " + keyword + " is already taken")
+	}
+	optionParsers[keyword] = parse
+}
+
+// OwnedKeyword reports whether keyword is parsed into a dedicated field
+// rather than kept in Options.
+func OwnedKeyword(keyword string) bool {
+	_, ok := optionParsers[keyword]
+	return ok
+}
+
+func init() {
+	registerOption("address", parseAddressOption)
+	registerOption("netmask", parseNetmaskOption)
+	registerOption("broadcast", parseBroadcastOption)
+	registerOption("gateway", parseGatewayOption)
+	registerOption("dns-nameservers", parseDNSServersOption)
+	registerOption("dns-search", parseDNSSearchOption)
+	registerOption("hwaddress", parseHWAddressOption)
+
+	registerOption("pre-up", hookOption(func(h *Hooks) *[]string { return &h.PreUp }))
+	registerOption("post-up", hookOption(func(h *Hooks) *[]string { return &h.PostUp }))
+	registerOption("pre-down", hookOption(func(h *Hooks) *[]string { return &h.PreDown }))
+	registerOption("post-down", hookOption(func(h *Hooks) *[]string { return &h.PostDown }))
+	// ifupdown treats up as an alias of post-up, and down as one of pre-down
+	registerOption("up", hookOption(func(h *Hooks) *[]string { return &h.PostUp }))
+	registerOption("down", hookOption(func(h *Hooks) *[]string { return &h.PreDown }))
+}
+
+func parseAddressOption(iface *NetworkInterface, value string) error {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return nil
+	}
+	if strings.Contains(fields[0], "/") {
+		prfx, _ := netip.ParsePrefix(fields[0])
+		iface.Address = net.ParseIP(prfx.Addr().String())
+		if iface.Address == nil {
+			return ErrInvalidIfaceData
+		}
+		iface.Netmask = net.CIDRMask(prfx.Bits(), prfx.Addr().BitLen())
+		return nil
+	}
+	iface.Address = net.ParseIP(fields[0])
+	if iface.Address == nil {
+		return ErrInvalidIfaceData
+	}
+	return nil
+}
+
+func parseNetmaskOption(iface *NetworkInterface, value string) error {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || iface.Netmask != nil {
+		// an address in CIDR form wins
+		return nil
+	}
+	mask, err := parseNetmask(fields[0], iface.Version)
+	if err != nil {
+		return ErrInvalidIfaceData
+	}
+	iface.Netmask = mask
+	return nil
+}
+
+func parseBroadcastOption(iface *NetworkInterface, value string) error {
+	fields := strings.Fields(value)
+	switch {
+	case len(fields) == 0:
+		return nil
+	case fields[0] == "+" || fields[0] == "-":
+		// computed by ifupdown from the address and netmask
+		iface.Options = append(iface.Options, Option{Key: "broadcast", Value: value})
+		return nil
+	}
+	iface.Broadcast = net.ParseIP(fields[0])
+	if iface.Broadcast == nil {
+		return ErrInvalidIfaceData
+	}
+	return nil
+}
+
+func parseGatewayOption(iface *NetworkInterface, value string) error {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return nil
+	}
+	iface.Gateway = net.ParseIP(fields[0])
+	if iface.Gateway == nil {
+		return ErrInvalidIfaceData
+	}
+	return nil
+}
+
+func parseDNSServersOption(iface *NetworkInterface, value string) error {
+	for _, fragment := range strings.Fields(value) {
+		ns := net.ParseIP(fragment)
+		if ns == nil {
+			return ErrInvalidIfaceData
+		}
+		iface.DNSServers = append(iface.DNSServers, ns)
+	}
+	return nil
+}
+
+func parseDNSSearchOption(iface *NetworkInterface, value string) error {
+	iface.DNSSearch = append(iface.DNSSearch, strings.Fields(value)...)
+	return nil
+}
+
+// parseHWAddressOption accepts both "hwaddress ether <mac>" and the newer
+// "hwaddress <mac>".
+func parseHWAddressOption(iface *NetworkInterface, value string) error {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return nil
+	}
+	mac := fields[len(fields)-1]
+	if len(fields) > 2 || (len(fields) == 2 && fields[0] != "ether") {
+		return ErrInvalidIfaceData
+	}
+	var err error
+	if iface.MACAddress, err = net.ParseMAC(mac); err != nil {
+		return ErrInvalidIfaceData
+	}
+	return nil
+}
+
+// hookOption returns a parser that appends the command to the hook list
+// picked by list.
+func hookOption(list func(hooks *Hooks) *[]string) optionParser {
+	return func(iface *NetworkInterface, value string) error {
+		if value == "" {
+			return nil
+		}
+		hooks := list(&iface.Hooks)
+		*hooks = append(*hooks, value)
+		return nil
+	}
+}
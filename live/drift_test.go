@@ -0,0 +1,135 @@
+package live
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"git.tcp.direct/kayos/ifupdown"
+)
+
+const driftConfig = `auto lo
+iface lo inet loopback
+
+auto eth0
+iface eth0 inet static
+	address 192.168.69.5
+	netmask 255.255.255.0
+	gateway 192.168.69.1
+	hwaddress ether 52:54:00:12:34:56
+	dns-nameservers 192.168.69.1 1.1.1.1
+	up ip -6 addr add 2001:db8::5/64 dev eth0
+
+auto eth1
+iface eth1 inet dhcp
+	hwaddress ether 52:54:00:ab:cd:00
+
+auto eth0.100
+iface eth0.100 inet6 static
+	address 2001:db8:100::2/64
+	gateway 2001:db8:100::1
+
+iface eth2 inet manual
+
+auto eth3
+iface eth3 inet dhcp
+
+allow-hotplug usb0
+iface usb0 inet dhcp
+`
+
+func parseConfig(t testing.TB, data string) ifupdown.Interfaces {
+	p := ifupdown.NewMultiParser()
+	if _, err := p.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	ifaces, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ifaces
+}
+
+func TestCompare(t *testing.T) {
+	resolv := "# managed by hand\nnameserver 192.168.69.1\nnameserver fe80::1%eth0\n"
+	report, err := Compare(parseConfig(t, driftConfig), testSnapshot(t), strings.NewReader(resolv))
+	if err != nil {
+		t.Fatalf("Compare() = %v", err)
+	}
+
+	var got []string
+	for _, d := range report.Drift {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"[eth0] unconfigured-address: got 192.168.69.6/24",
+		"[eth0] dns: want nameserver 1.1.1.1",
+		"[eth0.100] missing-address: want 2001:db8:100::2/64",
+		"[eth0.100] unconfigured-address: got 2001:db8:100::1/64",
+		"[eth0.100] missing-default-route: want default via 2001:db8:100::1, got default via 2001:db8:100::fffe",
+		"[eth1] mac: want 52:54:00:ab:cd:00, got 52:54:00:ab:cd:ef",
+		"[eth3] absent",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Compare() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if report.Status != StatusCritical {
+		t.Errorf("Status = %v, want %v", report.Status, StatusCritical)
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte(`{"status":"CRITICAL","drift":[{"interface":"eth0","kind":"unconfigured-address","got":"192.168.69.6/24"}`)) {
+		t.Errorf("MarshalJSON() = %s", data)
+	}
+}
+
+func TestCompare_DualStack(t *testing.T) {
+	config := `auto eth0
+iface eth0 inet static
+	address 192.168.69.5/24
+	gateway 192.168.69.1
+
+iface eth0 inet6 static
+	address 2001:db8::5/64
+	gateway 2001:db8::1
+`
+	report, err := Compare(parseConfig(t, config), testSnapshot(t), nil)
+	if err != nil {
+		t.Fatalf("Compare() = %v", err)
+	}
+	var got []string
+	for _, d := range report.Drift {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"[eth0] unconfigured-address: got 192.168.69.6/24",
+		"[eth0] missing-default-route: want default via 2001:db8::1",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Compare() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCompare_OK(t *testing.T) {
+	config := "auto lo\niface lo inet loopback\n\nauto eth1\niface eth1 inet dhcp\n"
+	report, err := Compare(parseConfig(t, config), testSnapshot(t), nil)
+	if err != nil {
+		t.Fatalf("Compare() = %v", err)
+	}
+	if report.Status != StatusOK || len(report.Drift) != 0 {
+		t.Errorf("Compare() = %+v, want no drift", report)
+	}
+
+	buf := &strings.Builder{}
+	if _, err = report.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "DRIFT OK - running state matches configuration\n" {
+		t.Errorf("WriteTo() = %q", buf)
+	}
+}
@@ -0,0 +1,5 @@
+package live
+
+import "errors"
+
+var ErrInvalidOutput = errors.New("invalid ip output")
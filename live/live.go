@@ -0,0 +1,377 @@
+// Package live imports the running network state of a host from the JSON
+// output of iproute2, as printed by ip -j link show, ip -j addr show and
+// ip -j route show.
+//
+// The readers can hold captured output, which is what the tests use, or be
+// piped straight from ip. Capture runs ip itself.
+package live
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os/exec"
+	"slices"
+	"sort"
+
+	"git.tcp.direct/kayos/ifupdown"
+)
+
+// Address is an address configured on a link.
+type Address struct {
+	Prefix    netip.Prefix `json:"prefix"`
+	Broadcast netip.Addr   `json:"broadcast"`
+	// Scope is global, link or host.
+	Scope string `json:"scope,omitempty"`
+	// Dynamic is set for addresses with a limited lifetime, such as the
+	// ones handed out by DHCP or SLAAC.
+	Dynamic bool `json:"dynamic,omitempty"`
+}
+
+// Link is a network interface as the kernel sees it.
+type Link struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	// Type is the link type, such as ether or loopback.
+	Type         string           `json:"type,omitempty"`
+	Flags        []string         `json:"flags,omitempty"`
+	State        string           `json:"state,omitempty"`
+	MTU          int              `json:"mtu,omitempty"`
+	HardwareAddr net.HardwareAddr `json:"hardware_addr,omitempty"`
+	// Master is the bridge or bond the link is enslaved to.
+	Master string `json:"master,omitempty"`
+	// Parent is the link this one sits on, such as the raw device of a VLAN.
+	Parent    string    `json:"parent,omitempty"`
+	Addresses []Address `json:"addresses,omitempty"`
+}
+
+// Up reports whether the link is administratively up.
+func (l *Link) Up() bool {
+	return slices.Contains(l.Flags, "UP")
+}
+
+// Route is a route in the kernel routing table.
+type Route struct {
+	// Dst is the destination, 0.0.0.0/0 or ::/0 for a default route.
+	Dst     netip.Prefix `json:"dst"`
+	Gateway netip.Addr   `json:"gateway"`
+	Dev     string       `json:"dev,omitempty"`
+	Metric  int          `json:"metric,omitempty"`
+	// Type is empty for unicast routes.
+	Type     string `json:"type,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+	// Table is empty for the main table.
+	Table string `json:"table,omitempty"`
+}
+
+// Default reports whether r is a default route.
+func (r Route) Default() bool {
+	return r.Dst.IsValid() && r.Dst.Bits() == 0
+}
+
+// Snapshot is the state of every link and route at one point in time.
+type Snapshot struct {
+	Links  map[string]*Link `json:"links"`
+	Routes []Route          `json:"routes,omitempty"`
+}
+
+// ipLink is an entry of ip -j link show and ip -j addr show.
+type ipLink struct {
+	IfIndex   int      `json:"ifindex"`
+	IfName    string   `json:"ifname"`
+	Link      string   `json:"link"`
+	Master    string   `json:"master"`
+	Flags     []string `json:"flags"`
+	MTU       int      `json:"mtu"`
+	OperState string   `json:"operstate"`
+	LinkType  string   `json:"link_type"`
+	Address   string   `json:"address"`
+	AddrInfo  []ipAddr `json:"addr_info"`
+}
+
+type ipAddr struct {
+	Family    string `json:"family"`
+	Local     string `json:"local"`
+	PrefixLen int    `json:"prefixlen"`
+	Broadcast string `json:"broadcast"`
+	Scope     string `json:"scope"`
+	Dynamic   bool   `json:"dynamic"`
+}
+
+// ipRoute is an entry of ip -j route show.
+type ipRoute struct {
+	Type     string `json:"type"`
+	Dst      string `json:"dst"`
+	Gateway  string `json:"gateway"`
+	Dev      string `json:"dev"`
+	Metric   int    `json:"metric"`
+	Protocol string `json:"protocol"`
+	Table    string `json:"table"`
+}
+
+// decode reads every JSON array in r, so the output of several ip
+// invocations can be concatenated into one reader.
+func decode[T any](r io.Reader) ([]T, error) {
+	if r == nil {
+		return nil, nil
+	}
+	var all []T
+	dec := json.NewDecoder(r)
+	for {
+		var batch []T
+		err := dec.Decode(&batch)
+		if errors.Is(err, io.EOF) {
+			return all, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidOutput, err)
+		}
+		all = append(all, batch...)
+	}
+}
+
+// Import builds a Snapshot from the output of ip -j link show, ip -j addr
+// show and ip -j route show. Any of the readers may be nil; as ip addr also
+// lists the links, link is only needed for links ip addr left out. Output
+// for several address families, e.g. of ip -j route and ip -j -6 route, can
+// be concatenated in the same reader.
+func Import(link, addr, route io.Reader) (*Snapshot, error) {
+	s := &Snapshot{Links: make(map[string]*Link)}
+
+	links, err := decode[ipLink](link)
+	if err != nil {
+		return nil, fmt.Errorf("link: %w", err)
+	}
+	addrs, err := decode[ipLink](addr)
+	if err != nil {
+		return nil, fmt.Errorf("addr: %w", err)
+	}
+	for _, l := range append(links, addrs...) {
+		if err = s.addLink(l); err != nil {
+			return nil, err
+		}
+	}
+
+	routes, err := decode[ipRoute](route)
+	if err != nil {
+		return nil, fmt.Errorf("route: %w", err)
+	}
+	for _, r := range routes {
+		if err = s.addRoute(r); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// addLink merges l into s. Addresses of a link listed more than once are
+// only added the first time they are seen.
+func (s *Snapshot) addLink(l ipLink) error {
+	if l.IfName == "" {
+		return fmt.Errorf("%w: link %d has no name", ErrInvalidOutput, l.IfIndex)
+	}
+	link, ok := s.Links[l.IfName]
+	if !ok {
+		link = &Link{Name: l.IfName}
+		s.Links[l.IfName] = link
+	}
+	link.Index = l.IfIndex
+	link.Type = l.LinkType
+	link.Flags = l.Flags
+	link.State = l.OperState
+	link.MTU = l.MTU
+	link.Master = l.Master
+	link.Parent = l.Link
+	if l.LinkType == "ether" && l.Address != "" {
+		mac, err := net.ParseMAC(l.Address)
+		if err != nil {
+			return fmt.Errorf("%w: [%s] %w", ErrInvalidOutput, l.IfName, err)
+		}
+		link.HardwareAddr = mac
+	}
+
+	for _, a := range l.AddrInfo {
+		addr, err := netip.ParseAddr(a.Local)
+		if err != nil {
+			return fmt.Errorf("%w: [%s] %w", ErrInvalidOutput, l.IfName, err)
+		}
+		prefix := netip.PrefixFrom(addr, a.PrefixLen)
+		if !prefix.IsValid() {
+			return fmt.Errorf("%w: [%s] prefix length %d of %s", ErrInvalidOutput, l.IfName, a.PrefixLen, addr)
+		}
+		address := Address{Prefix: prefix, Scope: a.Scope, Dynamic: a.Dynamic}
+		if a.Broadcast != "" {
+			if address.Broadcast, err = netip.ParseAddr(a.Broadcast); err != nil {
+				return fmt.Errorf("%w: [%s] %w", ErrInvalidOutput, l.IfName, err)
+			}
+		}
+		if !slices.ContainsFunc(link.Addresses, func(have Address) bool { return have.Prefix == prefix }) {
+			link.Addresses = append(link.Addresses, address)
+		}
+	}
+	return nil
+}
+
+// addRoute adds r to s. ip prints default routes as "default", which says
+// nothing about the family, so one without a gateway is taken to be IPv4.
+func (s *Snapshot) addRoute(r ipRoute) error {
+	route := Route{
+		Dev:      r.Dev,
+		Metric:   r.Metric,
+		Protocol: r.Protocol,
+		Table:    r.Table,
+	}
+	if r.Type != "unicast" {
+		route.Type = r.Type
+	}
+	if r.Table == "main" {
+		route.Table = ""
+	}
+
+	var err error
+	if r.Gateway != "" {
+		if route.Gateway, err = netip.ParseAddr(r.Gateway); err != nil {
+			return fmt.Errorf("%w: route %s: %w", ErrInvalidOutput, r.Dst, err)
+		}
+	}
+
+	switch {
+	case r.Dst == "default" && route.Gateway.Is6():
+		route.Dst = netip.PrefixFrom(netip.IPv6Unspecified(), 0)
+	case r.Dst == "default":
+		route.Dst = netip.PrefixFrom(netip.IPv4Unspecified(), 0)
+	default:
+		route.Dst, err = netip.ParsePrefix(r.Dst)
+		if err != nil {
+			// host routes come without a prefix length
+			var addr netip.Addr
+			if addr, err = netip.ParseAddr(r.Dst); err != nil {
+				return fmt.Errorf("%w: route %s: %w", ErrInvalidOutput, r.Dst, err)
+			}
+			route.Dst = netip.PrefixFrom(addr, addr.BitLen())
+		}
+	}
+	s.Routes = append(s.Routes, route)
+	return nil
+}
+
+// ipCommand is the iproute2 binary Capture runs.
+const ipCommand = "ip"
+
+// Capture takes a Snapshot of the running host by running ip.
+func Capture(ctx context.Context) (*Snapshot, error) {
+	run := func(args ...string) (*bytes.Buffer, error) {
+		out := &bytes.Buffer{}
+		cmd := exec.CommandContext(ctx, ipCommand, append([]string{"-j"}, args...)...)
+		cmd.Stdout = out
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("%s -j %v: %w", ipCommand, args, err)
+		}
+		return out, nil
+	}
+
+	addr, err := run("addr", "show")
+	if err != nil {
+		return nil, err
+	}
+	route, err := run("-4", "route", "show")
+	if err != nil {
+		return nil, err
+	}
+	route6, err := run("-6", "route", "show")
+	if err != nil {
+		return nil, err
+	}
+	return Import(nil, addr, io.MultiReader(route, route6))
+}
+
+// names returns the names of the links in s, sorted.
+func (s *Snapshot) names() []string {
+	names := make([]string, 0, len(s.Links))
+	for name := range s.Links {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultRoutes returns the default routes in the main table that go out of
+// the named link, lowest metric first.
+func (s *Snapshot) DefaultRoutes(dev string) []Route {
+	var routes []Route
+	for _, r := range s.Routes {
+		if r.Default() && r.Dev == dev && r.Table == "" && r.Type == "" {
+			routes = append(routes, r)
+		}
+	}
+	slices.SortStableFunc(routes, func(a, b Route) int { return a.Metric - b.Metric })
+	return routes
+}
+
+// primary returns the address that best represents l in a single stanza:
+// the first global IPv4 address, else the first global IPv6 address.
+func (l *Link) primary() (Address, bool) {
+	for _, want := range []func(netip.Addr) bool{netip.Addr.Is4, netip.Addr.Is6} {
+		for _, a := range l.Addresses {
+			if a.Scope == "global" && want(a.Prefix.Addr()) {
+				return a, true
+			}
+		}
+	}
+	return Address{}, false
+}
+
+// Interfaces describes s as ifupdown stanzas. As a stanza holds a single
+// address, each link gets its primary one: the first global IPv4 address,
+// else the first global IPv6 one. Links with a dynamic primary address are
+// described as dhcp, links without one as manual. Interfaces that are up are
+// marked auto.
+func (s *Snapshot) Interfaces() ifupdown.Interfaces {
+	ifaces := make(ifupdown.Interfaces, len(s.Links))
+	for _, name := range s.names() {
+		link := s.Links[name]
+		ifaces[name] = ifupdown.NewNetworkInterface(name).Update(func(iface *ifupdown.NetworkInterface) {
+			iface.Auto = link.Up()
+			iface.Version = ifupdown.AddressVersion4
+			if link.Type == "ether" {
+				iface.MACAddress = slices.Clone(link.HardwareAddr)
+			}
+
+			primary, ok := link.primary()
+			switch {
+			case link.Type == "loopback":
+				iface.Config = ifupdown.AddressConfigLoopback
+				return
+			case !ok:
+				iface.Config = ifupdown.AddressConfigManual
+				return
+			case primary.Prefix.Addr().Is6():
+				iface.Version = ifupdown.AddressVersion6
+			}
+			if primary.Dynamic {
+				iface.Config = ifupdown.AddressConfigDHCP
+				return
+			}
+
+			addr := primary.Prefix.Addr()
+			iface.Config = ifupdown.AddressConfigStatic
+			iface.Address = addr.AsSlice()
+			iface.Netmask = net.CIDRMask(primary.Prefix.Bits(), addr.BitLen())
+			if primary.Broadcast.IsValid() {
+				iface.Broadcast = primary.Broadcast.AsSlice()
+			}
+			for _, r := range s.DefaultRoutes(name) {
+				if r.Gateway.IsValid() && r.Gateway.Is6() == addr.Is6() {
+					iface.Gateway = r.Gateway.AsSlice()
+					break
+				}
+			}
+		})
+	}
+	return ifaces
+}
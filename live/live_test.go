@@ -0,0 +1,122 @@
+package live
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+
+	"git.tcp.direct/kayos/ifupdown"
+)
+
+func fixture(t testing.TB, name string) *os.File {
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+	return f
+}
+
+func testSnapshot(t testing.TB) *Snapshot {
+	s, err := Import(fixture(t, "link.json"), fixture(t, "addr.json"), fixture(t, "route.json"))
+	if err != nil {
+		t.Fatalf("Import() = %v", err)
+	}
+	return s
+}
+
+func TestImport(t *testing.T) {
+	s := testSnapshot(t)
+	if len(s.Links) != 5 {
+		t.Fatalf("got %d links, want 5", len(s.Links))
+	}
+
+	eth0 := s.Links["eth0"]
+	if !eth0.Up() || eth0.HardwareAddr.String() != "52:54:00:12:34:56" || eth0.Index != 2 {
+		t.Errorf("eth0 = %+v", eth0)
+	}
+	var addrs []string
+	for _, a := range eth0.Addresses {
+		addrs = append(addrs, a.Prefix.String())
+	}
+	want := "192.168.69.5/24 192.168.69.6/24 2001:db8::5/64 fe80::5054:ff:fe12:3456/64"
+	if got := strings.Join(addrs, " "); got != want {
+		t.Errorf("eth0 addresses = %s, want %s", got, want)
+	}
+	if s.Links["eth2"].Up() {
+		t.Error("eth2 is down, but Up() = true")
+	}
+	if s.Links["eth0.100"].Parent != "eth0" {
+		t.Errorf("eth0.100 parent = %q, want eth0", s.Links["eth0.100"].Parent)
+	}
+
+	if len(s.Routes) != 7 {
+		t.Fatalf("got %d routes, want 7", len(s.Routes))
+	}
+	routes := s.DefaultRoutes("eth0.100")
+	if len(routes) != 1 || routes[0].Dst != netip.MustParsePrefix("::/0") ||
+		routes[0].Gateway != netip.MustParseAddr("2001:db8:100::fffe") {
+		t.Errorf("DefaultRoutes(eth0.100) = %v", routes)
+	}
+}
+
+func TestImport_Invalid(t *testing.T) {
+	for name, input := range map[string][3]string{
+		"json":    {"", "[{", ""},
+		"mac":     {`[{"ifname":"eth0","link_type":"ether","address":"nope"}]`, "", ""},
+		"address": {"", `[{"ifname":"eth0","addr_info":[{"local":"10.0.0.300","prefixlen":8}]}]`, ""},
+		"prefix":  {"", `[{"ifname":"eth0","addr_info":[{"local":"10.0.0.1","prefixlen":33}]}]`, ""},
+		"route":   {"", "", `[{"dst":"10.0.0.0/33","dev":"eth0"}]`},
+		"noname":  {`[{"ifindex":3}]`, "", ""},
+	} {
+		_, err := Import(strings.NewReader(input[0]), strings.NewReader(input[1]), strings.NewReader(input[2]))
+		if !errors.Is(err, ErrInvalidOutput) {
+			t.Errorf("%s: Import() = %v, want %v", name, err, ErrInvalidOutput)
+		}
+	}
+}
+
+func TestSnapshot_Interfaces(t *testing.T) {
+	ifaces := testSnapshot(t).Interfaces()
+
+	want := map[string]string{
+		"lo": "auto lo\n" +
+			"iface lo inet loopback\n\n",
+		"eth0": "auto eth0\n" +
+			"iface eth0 inet static\n" +
+			"\taddress 192.168.69.5\n" +
+			"\tnetmask 255.255.255.0\n" +
+			"\tbroadcast 192.168.69.255\n" +
+			"\tgateway 192.168.69.1\n" +
+			"\thwaddress ether 52:54:00:12:34:56\n\n",
+		"eth1": "auto eth1\n" +
+			"iface eth1 inet dhcp\n" +
+			"\thwaddress ether 52:54:00:ab:cd:ef\n\n",
+		"eth2": "iface eth2 inet manual\n" +
+			"\thwaddress ether 52:54:00:00:00:02\n\n",
+		"eth0.100": "auto eth0.100\n" +
+			"iface eth0.100 inet6 static\n" +
+			"\taddress 2001:db8:100::1\n" +
+			"\tnetmask ffff:ffff:ffff:ffff::\n" +
+			"\tgateway 2001:db8:100::fffe\n" +
+			"\thwaddress ether 52:54:00:12:34:56\n\n",
+	}
+	if len(ifaces) != len(want) {
+		t.Fatalf("got %d interfaces, want %d", len(ifaces), len(want))
+	}
+	for name, text := range want {
+		buf := &strings.Builder{}
+		enc := ifupdown.NewEncoder(buf)
+		if err := enc.Encode(ifaces[name]); err != nil {
+			t.Fatalf("[%s] Encode() = %v", name, err)
+		}
+		if err := enc.Flush(); err != nil {
+			t.Fatalf("[%s] Flush() = %v", name, err)
+		}
+		if buf.String() != text {
+			t.Errorf("[%s] got %q, want %q", name, buf, text)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package ifupdown
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Mapping is a mapping stanza. It picks the logical interface, that is the
+// iface stanza, a physical interface is brought up with by running a script:
+//
+//	mapping eth0
+//		script /usr/local/sbin/map-scheme
+//		map HOME eth0-home
+//		map WORK eth0-work
+type Mapping struct {
+	// Patterns are the interface names the mapping applies to. They may
+	// contain shell wildcards.
+	Patterns []string `json:"patterns"`
+	// Script is run with the physical interface name as its argument and
+	// prints the logical interface name.
+	Script string `json:"script"`
+	// Map holds the map lines, without the keyword, that are fed to the
+	// script on stdin.
+	Map []string `json:"map,omitempty"`
+}
+
+// Matches reports whether the mapping applies to the interface name.
+func (m *Mapping) Matches(name string) bool {
+	for _, pattern := range m.Patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate reports whether the mapping can be written out and run.
+func (m *Mapping) Validate() error {
+	switch {
+	case len(m.Patterns) == 0:
+		return fmt.Errorf("%w: no interfaces", ErrInvalidMapping)
+	case m.Script == "":
+		return fmt.Errorf("[mapping %s] %w: no script", strings.Join(m.Patterns, " "), ErrInvalidMapping)
+	default:
+		return nil
+	}
+}
+
+func (m *Mapping) String() string {
+	var b strings.Builder
+	b.WriteString("mapping ")
+	b.WriteString(strings.Join(m.Patterns, " "))
+	b.WriteString("\n\tscript ")
+	b.WriteString(m.Script)
+	b.WriteString("\n")
+	for _, line := range m.Map {
+		b.WriteString("\tmap ")
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// MappingScript is a mapping script about to be run for an interface.
+type MappingScript struct {
+	// Script is the path of the executable.
+	Script string
+	// Physical is the name of the physical interface, passed as the only
+	// argument.
+	Physical string
+	// Input is written to the script's stdin: the map lines of the stanza,
+	// one per line.
+	Input []byte
+}
+
+// MappingRunner executes mapping scripts.
+type MappingRunner interface {
+	// Run executes script and returns what it wrote to stdout.
+	Run(ctx context.Context, script MappingScript) ([]byte, error)
+}
+
+// MappingFunc adapts a function to a MappingRunner.
+type MappingFunc func(ctx context.Context, script MappingScript) ([]byte, error)
+
+func (f MappingFunc) Run(ctx context.Context, script MappingScript) ([]byte, error) {
+	return f(ctx, script)
+}
+
+// ScriptRunner runs mapping scripts directly, without a shell, the way
+// ifupdown does.
+type ScriptRunner struct {
+	// Timeout limits how long each script may run. Zero means no limit
+	// other than the context passed to Run.
+	Timeout time.Duration
+}
+
+func (r *ScriptRunner) Run(ctx context.Context, script MappingScript) ([]byte, error) {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, script.Script, script.Physical)
+	cmd.Stdin = bytes.NewReader(script.Input)
+	cmd.WaitDelay = time.Second
+	out, err := cmd.Output()
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w: %w", ErrMappingTimeout, err)
+	}
+	return out, err
+}
+
+// Run runs the mapping script for the physical interface and returns the
+// logical interface it picked: the first line of its output. A script that
+// prints nothing leaves the interface unmapped, so physical is returned.
+func (m *Mapping) Run(ctx context.Context, runner MappingRunner, physical string) (string, error) {
+	logical, err := m.run(ctx, runner, physical)
+	if err != nil || logical != "" {
+		return logical, err
+	}
+	return physical, nil
+}
+
+// run is Run without the fallback, returning "" if the script printed nothing.
+func (m *Mapping) run(ctx context.Context, runner MappingRunner, physical string) (string, error) {
+	if err := m.Validate(); err != nil {
+		return "", err
+	}
+	var input bytes.Buffer
+	for _, line := range m.Map {
+		input.WriteString(line)
+		input.WriteByte('\n')
+	}
+	out, err := runner.Run(ctx, MappingScript{Script: m.Script, Physical: physical, Input: input.Bytes()})
+	if err != nil {
+		return "", fmt.Errorf("[%s] mapping %s: %w", physical, m.Script, err)
+	}
+	first, _, _ := strings.Cut(string(out), "\n")
+	return strings.TrimSpace(first), nil
+}
+
+// Mappings are the mapping stanzas of an interfaces file, in file order.
+type Mappings []*Mapping
+
+// Resolve returns the logical interface physical is brought up with. Like
+// ifup, it runs every mapping in order whose patterns match the name picked
+// so far, so mappings can be chained. A script that prints nothing keeps the
+// name picked so far.
+func (ms Mappings) Resolve(ctx context.Context, runner MappingRunner, physical string) (string, error) {
+	logical := physical
+	for _, m := range ms {
+		if !m.Matches(logical) {
+			continue
+		}
+		picked, err := m.run(ctx, runner, physical)
+		if err != nil {
+			return "", err
+		}
+		if picked != "" {
+			logical = picked
+		}
+	}
+	return logical, nil
+}
+
+func (ms Mappings) String() string {
+	var b strings.Builder
+	for _, m := range ms {
+		b.WriteString(m.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// decodeMapping reads the options of a mapping stanza for patterns.
+func (d *Decoder) decodeMapping(patterns []string) (*Mapping, error) {
+	m := &Mapping{Patterns: slices.Clone(patterns)}
+	var err error
+	for {
+		line, ok := d.line()
+		if !ok {
+			break
+		}
+		fields := tokens(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if stanzaStart(fields[0]) {
+			d.unread(line)
+			break
+		}
+		value := strings.Join(fields[1:], " ")
+		switch fields[0] {
+		case "script":
+			m.Script = value
+		case "map":
+			m.Map = append(m.Map, value)
+		default:
+			if err == nil {
+				err = fmt.Errorf("[mapping %s] %w: unknown option %q", strings.Join(patterns, " "), ErrInvalidMapping, fields[0])
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, m.Validate()
+}
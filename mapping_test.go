@@ -0,0 +1,135 @@
+package ifupdown
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const mappingFile = `mapping eth0 wlan*
+	script /usr/local/sbin/map-scheme
+	map HOME eth0-home
+	map WORK eth0-work
+
+auto eth0-home
+iface eth0-home inet dhcp
+
+mapping eth0-home
+	script /usr/local/sbin/pick-vlan
+`
+
+func TestMultiParser_Mappings(t *testing.T) {
+	mp := NewMultiParser()
+	_, _ = mp.Write([]byte(mappingFile))
+	ifaces, err := mp.Parse()
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	if len(ifaces) != 1 || ifaces["eth0-home"] == nil {
+		t.Errorf("Parse() = %v, want only eth0-home", ifaces.names())
+	}
+	if len(mp.Mappings) != 2 {
+		t.Fatalf("got %d mappings, want 2", len(mp.Mappings))
+	}
+
+	m := mp.Mappings[0]
+	if !m.Matches("eth0") || !m.Matches("wlan1") || m.Matches("eth1") {
+		t.Errorf("Matches() wrong for %v", m.Patterns)
+	}
+	want := "mapping eth0 wlan*\n\tscript /usr/local/sbin/map-scheme\n\tmap HOME eth0-home\n\tmap WORK eth0-work\n"
+	if m.String() != want {
+		t.Errorf("String() = %q, want %q", m.String(), want)
+	}
+
+	// rendering and parsing again gives the same mappings
+	mp2 := NewMultiParser()
+	_, _ = mp2.Write([]byte(mp.Mappings.String()))
+	if _, err = mp2.Parse(); err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	if mp2.Mappings.String() != mp.Mappings.String() {
+		t.Errorf("round trip = %q, want %q", mp2.Mappings.String(), mp.Mappings.String())
+	}
+}
+
+func TestDecoder_InvalidMapping(t *testing.T) {
+	for _, data := range []string{
+		"mapping eth0\n\tmap HOME eth0-home\n",
+		"mapping\n\tscript /bin/true\n",
+		"mapping eth0\n\tscript /bin/true\n\tbogus\n",
+	} {
+		mp := NewMultiParser()
+		_, _ = mp.Write([]byte(data + "\nauto lo\niface lo inet loopback\n"))
+		ifaces, err := mp.Parse()
+		if !errors.Is(err, ErrInvalidMapping) {
+			t.Errorf("%q: Parse() = %v, want %v", data, err, ErrInvalidMapping)
+		}
+		if ifaces["lo"] == nil {
+			t.Errorf("%q: stanza after invalid mapping lost", data)
+		}
+	}
+}
+
+func TestMappings_Resolve(t *testing.T) {
+	mp := NewMultiParser()
+	_, _ = mp.Write([]byte(mappingFile))
+	if _, err := mp.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	var ran []MappingScript
+	runner := MappingFunc(func(_ context.Context, script MappingScript) ([]byte, error) {
+		ran = append(ran, script)
+		switch script.Script {
+		case "/usr/local/sbin/map-scheme":
+			return []byte("eth0-home\n"), nil
+		default:
+			return nil, nil
+		}
+	})
+
+	logical, err := mp.Mappings.Resolve(context.Background(), runner, "eth0")
+	if err != nil || logical != "eth0-home" {
+		t.Errorf("Resolve(eth0) = %q, %v, want eth0-home", logical, err)
+	}
+	if len(ran) != 2 || ran[1].Physical != "eth0" || string(ran[0].Input) != "HOME eth0-home\nWORK eth0-work\n" {
+		t.Errorf("ran %+v", ran)
+	}
+
+	ran = nil
+	if logical, err = mp.Mappings.Resolve(context.Background(), runner, "eth1"); err != nil || logical != "eth1" || ran != nil {
+		t.Errorf("Resolve(eth1) = %q, %v after running %v, want eth1 unmapped", logical, err, ran)
+	}
+
+	failing := MappingFunc(func(context.Context, MappingScript) ([]byte, error) {
+		return nil, os.ErrPermission
+	})
+	if _, err = mp.Mappings.Resolve(context.Background(), failing, "eth0"); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("Resolve() = %v, want %v", err, os.ErrPermission)
+	}
+}
+
+func TestScriptRunner(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	script := filepath.Join(t.TempDir(), "map-scheme")
+	err := os.WriteFile(script, []byte("#!/bin/sh\nwhile read scheme iface; do\n\t[ \"$scheme\" = WORK ] && echo \"$iface\"\ndone\necho \"$1\"\n"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Mapping{Patterns: []string{"eth0"}, Script: script, Map: []string{"HOME eth0-home", "WORK eth0-work"}}
+	logical, err := m.Run(context.Background(), &ScriptRunner{}, "eth0")
+	if err != nil || logical != "eth0-work" {
+		t.Errorf("Run() = %q, %v, want eth0-work", logical, err)
+	}
+
+	m.Script = filepath.Join(filepath.Dir(script), "missing")
+	if _, err = m.Run(context.Background(), &ScriptRunner{}, "eth0"); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Run() = %v, want error for missing script", err)
+	}
+}
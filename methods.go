@@ -0,0 +1,367 @@
+package ifupdown
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// methodFamilies lists the address families each method of interfaces(5)
+// exists for.
+var methodFamilies = map[AddressConfig][]AddressVersion{
+	AddressConfigLoopback: {AddressVersion4, AddressVersion6},
+	AddressConfigStatic:   {AddressVersion4, AddressVersion6},
+	AddressConfigManual:   {AddressVersion4, AddressVersion6},
+	AddressConfigDHCP:     {AddressVersion4, AddressVersion6},
+	AddressConfigAuto:     {AddressVersion6},
+	AddressConfigBootp:    {AddressVersion4},
+	AddressConfigTunnel:   {AddressVersion4},
+	AddressConfigPPP:      {AddressVersion4},
+	AddressConfigWvdial:   {AddressVersion4},
+	AddressConfigIPv4LL:   {AddressVersion4},
+	AddressConfigV4Tunnel: {AddressVersion6},
+	AddressConfig6to4:     {AddressVersion6},
+}
+
+// methodOptions lists the methods each method-specific option is valid for.
+var methodOptions = map[string][]AddressConfig{
+	"metric":     {AddressConfigStatic, AddressConfigDHCP, AddressConfigTunnel},
+	"hostname":   {AddressConfigDHCP},
+	"leasehours": {AddressConfigDHCP},
+	"leasetime":  {AddressConfigDHCP},
+	"vendor":     {AddressConfigDHCP},
+	"client":     {AddressConfigDHCP},
+	"mode":       {AddressConfigTunnel},
+	"endpoint":   {AddressConfigTunnel, AddressConfigV4Tunnel},
+	"dstaddr":    {AddressConfigTunnel},
+	"local":      {AddressConfigTunnel, AddressConfigV4Tunnel, AddressConfig6to4},
+	"ttl":        {AddressConfigTunnel, AddressConfigV4Tunnel, AddressConfig6to4},
+	"provider":   {AddressConfigPPP, AddressConfigWvdial},
+	"unit":       {AddressConfigPPP},
+	"options":    {AddressConfigPPP},
+	"bootfile":   {AddressConfigBootp},
+	"server":     {AddressConfigBootp},
+	"hwaddr":     {AddressConfigBootp},
+}
+
+// tunnelModes are the modes of the inet tunnel method.
+var tunnelModes = []string{"GRE", "IPIP"}
+
+// Tunnel holds the options of the tunnel, v4tunnel and 6to4 methods.
+type Tunnel struct {
+	// Mode is GRE or IPIP, for the tunnel method.
+	Mode string `json:"mode,omitempty"`
+	// Endpoint is the address of the other end of the tunnel.
+	Endpoint net.IP `json:"endpoint,omitempty"`
+	// Local is the address of this end of the tunnel.
+	Local net.IP `json:"local,omitempty"`
+	// DstAddr is the remote address inside the tunnel.
+	DstAddr net.IP `json:"dstaddr,omitempty"`
+	// TTL of the packets carrying the tunnel.
+	TTL *int `json:"ttl,omitempty"`
+}
+
+// PPP holds the options of the ppp and wvdial methods.
+type PPP struct {
+	// Provider names the pppd peer or wvdial section to use.
+	Provider string `json:"provider,omitempty"`
+	// Unit is the ppp unit number, for the ppp method.
+	Unit *int `json:"unit,omitempty"`
+	// Options are passed to pppd as they are, for the ppp method.
+	Options string `json:"options,omitempty"`
+}
+
+// Bootp holds the options of the bootp method.
+type Bootp struct {
+	// BootFile to ask the server for.
+	BootFile string `json:"bootfile,omitempty"`
+	// Server to ask instead of broadcasting.
+	Server net.IP `json:"server,omitempty"`
+	// HWAddr to use instead of the one of the interface.
+	HWAddr net.HardwareAddr `json:"hwaddr,omitempty"`
+}
+
+func init() {
+	registerOption("metric", func(iface *NetworkInterface, value string) error {
+		if value == "" {
+			return nil
+		}
+		return parseIntOption(&iface.Metric, value, 0, 1<<32-1)
+	})
+
+	registerOption("mode", tunnelOption(func(t *Tunnel, v string) error {
+		t.Mode = v
+		return nil
+	}))
+	registerOption("endpoint", tunnelOption(func(t *Tunnel, v string) error {
+		return parseIPOption(&t.Endpoint, v)
+	}))
+	registerOption("local", tunnelOption(func(t *Tunnel, v string) error {
+		return parseIPOption(&t.Local, v)
+	}))
+	registerOption("dstaddr", tunnelOption(func(t *Tunnel, v string) error {
+		return parseIPOption(&t.DstAddr, v)
+	}))
+	registerOption("ttl", tunnelOption(func(t *Tunnel, v string) error {
+		return parseIntOption(&t.TTL, v, 0, 255)
+	}))
+
+	registerOption("provider", pppOption(func(p *PPP, v string) error {
+		p.Provider = v
+		return nil
+	}))
+	registerOption("unit", pppOption(func(p *PPP, v string) error {
+		return parseIntOption(&p.Unit, v, 0, 1<<16)
+	}))
+	registerOption("options", pppOption(func(p *PPP, v string) error {
+		p.Options = v
+		return nil
+	}))
+
+	registerOption("bootfile", bootpOption(func(b *Bootp, v string) error {
+		b.BootFile = v
+		return nil
+	}))
+	registerOption("server", bootpOption(func(b *Bootp, v string) error {
+		return parseIPOption(&b.Server, v)
+	}))
+	registerOption("hwaddr", bootpOption(func(b *Bootp, v string) error {
+		mac, err := net.ParseMAC(v)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidIfaceData, v)
+		}
+		b.HWAddr = mac
+		return nil
+	}))
+}
+
+func tunnelOption(set func(t *Tunnel, value string) error) optionParser {
+	return func(iface *NetworkInterface, value string) error {
+		if value == "" {
+			return nil
+		}
+		if iface.Tunnel == nil {
+			iface.Tunnel = &Tunnel{}
+		}
+		return set(iface.Tunnel, value)
+	}
+}
+
+func pppOption(set func(p *PPP, value string) error) optionParser {
+	return func(iface *NetworkInterface, value string) error {
+		if value == "" {
+			return nil
+		}
+		if iface.PPP == nil {
+			iface.PPP = &PPP{}
+		}
+		return set(iface.PPP, value)
+	}
+}
+
+func bootpOption(set func(b *Bootp, value string) error) optionParser {
+	return func(iface *NetworkInterface, value string) error {
+		if value == "" {
+			return nil
+		}
+		if iface.Bootp == nil {
+			iface.Bootp = &Bootp{}
+		}
+		return set(iface.Bootp, value)
+	}
+}
+
+// parseIPOption parses value into *dst.
+func parseIPOption(dst *net.IP, value string) error {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return fmt.Errorf("%w: %s", ErrInvalidIfaceData, value)
+	}
+	*dst = ip
+	return nil
+}
+
+// Clone returns a deep copy of t.
+func (t *Tunnel) Clone() *Tunnel {
+	if t == nil {
+		return nil
+	}
+	return &Tunnel{
+		Mode:     t.Mode,
+		Endpoint: slices.Clone(t.Endpoint),
+		Local:    slices.Clone(t.Local),
+		DstAddr:  slices.Clone(t.DstAddr),
+		TTL:      clonePtr(t.TTL),
+	}
+}
+
+// Clone returns a deep copy of p.
+func (p *PPP) Clone() *PPP {
+	if p == nil {
+		return nil
+	}
+	return &PPP{Provider: p.Provider, Unit: clonePtr(p.Unit), Options: p.Options}
+}
+
+// Clone returns a deep copy of b.
+func (b *Bootp) Clone() *Bootp {
+	if b == nil {
+		return nil
+	}
+	return &Bootp{BootFile: b.BootFile, Server: slices.Clone(b.Server), HWAddr: slices.Clone(b.HWAddr)}
+}
+
+func (t *Tunnel) options() []Option {
+	if t == nil {
+		return nil
+	}
+	var opts []Option
+	if t.Mode != "" {
+		opts = append(opts, Option{Key: "mode", Value: t.Mode})
+	}
+	if t.Endpoint != nil {
+		opts = append(opts, Option{Key: "endpoint", Value: t.Endpoint.String()})
+	}
+	if t.Local != nil {
+		opts = append(opts, Option{Key: "local", Value: t.Local.String()})
+	}
+	if t.DstAddr != nil {
+		opts = append(opts, Option{Key: "dstaddr", Value: t.DstAddr.String()})
+	}
+	if t.TTL != nil {
+		opts = append(opts, Option{Key: "ttl", Value: strconv.Itoa(*t.TTL)})
+	}
+	return opts
+}
+
+func (p *PPP) options() []Option {
+	if p == nil {
+		return nil
+	}
+	var opts []Option
+	if p.Provider != "" {
+		opts = append(opts, Option{Key: "provider", Value: p.Provider})
+	}
+	if p.Unit != nil {
+		opts = append(opts, Option{Key: "unit", Value: strconv.Itoa(*p.Unit)})
+	}
+	if p.Options != "" {
+		opts = append(opts, Option{Key: "options", Value: p.Options})
+	}
+	return opts
+}
+
+func (b *Bootp) options() []Option {
+	if b == nil {
+		return nil
+	}
+	var opts []Option
+	if b.BootFile != "" {
+		opts = append(opts, Option{Key: "bootfile", Value: b.BootFile})
+	}
+	if b.Server != nil {
+		opts = append(opts, Option{Key: "server", Value: b.Server.String()})
+	}
+	if b.HWAddr != nil {
+		opts = append(opts, Option{Key: "hwaddr", Value: b.HWAddr.String()})
+	}
+	return opts
+}
+
+// methodSpecific lists the method-specific options of iface, in the order
+// they are written. The caller must hold at least the read lock.
+func (iface *NetworkInterface) methodSpecific() []Option {
+	var opts []Option
+	if iface.Metric != nil {
+		opts = append(opts, Option{Key: "metric", Value: strconv.Itoa(*iface.Metric)})
+	}
+	opts = append(opts, iface.DHCP.options()...)
+	opts = append(opts, iface.Tunnel.options()...)
+	opts = append(opts, iface.PPP.options()...)
+	opts = append(opts, iface.Bootp.options()...)
+	return opts
+}
+
+// hasAddress reports whether the method of iface takes an address option.
+func (iface *NetworkInterface) hasAddress() bool {
+	switch iface.Config {
+	case AddressConfigStatic, AddressConfigManual, AddressConfigTunnel, AddressConfigV4Tunnel:
+		return true
+	default:
+		return false
+	}
+}
+
+// takes reports whether the method of iface takes the option called key.
+// Method-specific options on stanzas of other methods are kept in Options as
+// they are, since keywords like mode or server mean other things to other
+// tools. The caller must hold at least the read lock.
+func (iface *NetworkInterface) takes(key string) bool {
+	methods, ok := methodOptions[key]
+	return !ok || iface.Config == AddressConfigUnset || slices.Contains(methods, iface.Config)
+}
+
+// validateMethod checks that the method of iface exists for its family, that
+// its required options are set and that no option of another method is.
+// The caller must hold the write lock.
+func (iface *NetworkInterface) validateMethod() []error {
+	var errs []error
+	fail := func(err error, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("[%s] %w: "+format, append([]any{iface.Name, err}, args...)...))
+	}
+
+	if families, ok := methodFamilies[iface.Config]; ok && iface.Version != AddressVersionNil &&
+		!slices.Contains(families, iface.Version) {
+		fail(ErrWrongFamily, "method %s in %s stanza", iface.Config, iface.Version)
+	}
+
+	var wrong []string
+	for _, opt := range iface.methodSpecific() {
+		if !slices.Contains(methodOptions[opt.Key], iface.Config) {
+			wrong = append(wrong, opt.Key)
+		}
+	}
+	if len(wrong) > 0 {
+		fail(ErrWrongMethod, "%s with method %s", strings.Join(wrong, ", "), iface.Config)
+	}
+
+	tunnel := iface.Tunnel
+	if tunnel == nil {
+		tunnel = &Tunnel{}
+	}
+	switch iface.Config {
+	case AddressConfigTunnel:
+		switch {
+		case iface.Address == nil:
+			fail(ErrMissingOption, "address")
+		case iface.Address.IsUnspecified():
+			fail(ErrInvalidAddress, "%s", iface.Address)
+		}
+		if tunnel.Endpoint == nil {
+			fail(ErrMissingOption, "endpoint")
+		}
+		switch {
+		case tunnel.Mode == "":
+			fail(ErrMissingOption, "mode")
+		case !slices.Contains(tunnelModes, strings.ToUpper(tunnel.Mode)):
+			fail(ErrInvalidIfaceData, "mode %s", tunnel.Mode)
+		}
+	case AddressConfigV4Tunnel:
+		if tunnel.Endpoint == nil {
+			fail(ErrMissingOption, "endpoint")
+		}
+	case AddressConfig6to4:
+		if tunnel.Local == nil {
+			fail(ErrMissingOption, "local")
+		}
+	}
+	for _, ip := range []net.IP{tunnel.Endpoint, tunnel.Local} {
+		if ip != nil && ip.To4() == nil {
+			// every tunnel method carries its packets over IPv4
+			fail(ErrWrongFamily, "tunnel address %s", ip)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,170 @@
+package ifupdown
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const methodsFile = `iface gre1 inet tunnel
+	address 10.8.0.1
+	netmask 255.255.255.252
+	mode GRE
+	endpoint 198.51.100.7
+	local 192.0.2.1
+	dstaddr 10.8.0.2
+	ttl 64
+	metric 10
+
+iface ppp0 inet ppp
+	provider dsl
+	unit 0
+	options noipdefault
+
+iface ppp1 inet wvdial
+	provider modem
+
+iface eth1 inet bootp
+	bootfile pxelinux.0
+	server 192.0.2.5
+	hwaddr 52:54:00:12:34:56
+
+iface eth2 inet ipv4ll
+
+iface he0 inet6 v4tunnel
+	address 2001:db8:1::2
+	netmask 64
+	endpoint 203.0.113.1
+	ttl 255
+
+iface tun6to4 inet6 6to4
+	local 192.0.2.1
+`
+
+func TestNetworkInterface_Methods(t *testing.T) {
+	ifaces := parseInterfaces(t, methodsFile)
+
+	for name, config := range map[string]AddressConfig{
+		"gre1":    AddressConfigTunnel,
+		"ppp0":    AddressConfigPPP,
+		"ppp1":    AddressConfigWvdial,
+		"eth1":    AddressConfigBootp,
+		"eth2":    AddressConfigIPv4LL,
+		"he0":     AddressConfigV4Tunnel,
+		"tun6to4": AddressConfig6to4,
+	} {
+		iface := ifaces[name]
+		if iface == nil || iface.Config != config {
+			t.Errorf("[%s] = %v, want method %s", name, iface, config)
+			continue
+		}
+		if err := iface.Validate(); err != nil {
+			t.Errorf("[%s] Validate() = %v", name, err)
+		}
+	}
+
+	gre := ifaces["gre1"]
+	if tun := gre.Tunnel; tun == nil || tun.Mode != "GRE" || tun.Endpoint.String() != "198.51.100.7" || *tun.TTL != 64 {
+		t.Errorf("gre1 tunnel = %+v", gre.Tunnel)
+	}
+	if gre.Metric == nil || *gre.Metric != 10 {
+		t.Errorf("gre1 metric = %v", gre.Metric)
+	}
+	if p := ifaces["ppp0"].PPP; p == nil || p.Provider != "dsl" || *p.Unit != 0 || p.Options != "noipdefault" {
+		t.Errorf("ppp0 = %+v", p)
+	}
+	if b := ifaces["eth1"].Bootp; b == nil || b.BootFile != "pxelinux.0" || b.HWAddr.String() != "52:54:00:12:34:56" {
+		t.Errorf("eth1 bootp = %+v", b)
+	}
+
+	want := "iface gre1 inet tunnel\n" +
+		"\taddress 10.8.0.1\n" +
+		"\tnetmask 255.255.255.252\n" +
+		"\tmetric 10\n" +
+		"\tmode GRE\n" +
+		"\tendpoint 198.51.100.7\n" +
+		"\tlocal 192.0.2.1\n" +
+		"\tdstaddr 10.8.0.2\n" +
+		"\tttl 64\n"
+	if got := gre.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+
+	again := parseInterfaces(t, ifaces.String())
+	if got, want := again.String(), ifaces.String(); got != want {
+		t.Errorf("round trip:\n%s\nwant:\n%s", got, want)
+	}
+
+	clone := gre.Clone()
+	clone.Tunnel.Endpoint[0] = 1
+	*clone.Metric = 20
+	if gre.Tunnel.Endpoint.String() != "198.51.100.7" || *gre.Metric != 10 {
+		t.Error("Clone() shares method options with the original")
+	}
+}
+
+func TestNetworkInterface_OtherMethodOptions(t *testing.T) {
+	ifaces := parseInterfaces(t, `iface br0 inet manual
+	mode 802.3ad
+	server 10.0.0.1
+	hostname br0
+
+iface eth0 inet dhcp
+	hostname eth0
+`)
+	br0 := ifaces["br0"]
+	if br0.Tunnel != nil || br0.Bootp != nil || br0.DHCP != nil {
+		t.Errorf("tunnel = %+v, bootp = %+v, dhcp = %+v", br0.Tunnel, br0.Bootp, br0.DHCP)
+	}
+	if len(br0.Options) != 3 || br0.Options[0] != (Option{Key: "mode", Value: "802.3ad"}) {
+		t.Errorf("Options = %v", br0.Options)
+	}
+	if err := br0.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if !strings.Contains(br0.String(), "\tserver 10.0.0.1\n") {
+		t.Errorf("String() =\n%s", br0)
+	}
+	if d := ifaces["eth0"].DHCP; d == nil || d.Hostname != "eth0" {
+		t.Errorf("DHCP = %+v", d)
+	}
+}
+
+func TestNetworkInterface_ValidateMethod(t *testing.T) {
+	for stanza, want := range map[string]error{
+		"iface eth0 inet tunnel\n\tmode GRE\n\tendpoint 198.51.100.7\n":                       ErrMissingOption,
+		"iface eth0 inet tunnel\n\taddress 10.8.0.1\n\tendpoint 198.51.100.7\n":               ErrMissingOption,
+		"iface eth0 inet tunnel\n\taddress 0.0.0.0\n\tmode GRE\n\tendpoint 198.51.100.7\n":    ErrInvalidAddress,
+		"iface eth0 inet tunnel\n\taddress 10.8.0.1\n\tmode VXLAN\n\tendpoint 198.51.100.7\n": ErrInvalidIfaceData,
+		"iface eth0 inet6 v4tunnel\n\taddress 2001:db8::2\n":                                  ErrMissingOption,
+		"iface eth0 inet6 v4tunnel\n\tendpoint 2001:db8::1\n":                                 ErrWrongFamily,
+		"iface eth0 inet6 6to4\n":                                                            ErrMissingOption,
+		"iface eth0 inet6 ppp\n":                                                             ErrWrongFamily,
+		"iface eth0 inet v4tunnel\n\tendpoint 198.51.100.7\n":                                ErrWrongFamily,
+		"iface eth0 inet static\n\taddress 10.0.0.2/24\n\tprovider dsl\n":                    nil,
+		"iface eth0 inet dhcp\n\tendpoint 198.51.100.7\n":                                    nil,
+		"iface eth0 inet manual\n\tmetric 5\n":                                               nil,
+		"iface eth0 inet ppp\n":                                                              nil,
+		"iface eth0 inet tunnel\n\taddress 10.8.0.1\n\tmode ipip\n\tendpoint 198.51.100.7\n": nil,
+		"iface eth0 inet static\n\taddress 10.0.0.2/24\n\tgateway 10.0.0.1\n\tmetric 100\n":  nil,
+	} {
+		err := parseInterfaces(t, stanza)["eth0"].Validate()
+		if !errors.Is(err, want) || (want == nil && err != nil) {
+			t.Errorf("%q: Validate() = %v, want %v", stanza, err, want)
+		}
+	}
+
+	// set by hand rather than parsed, an option of another method is an error
+	manual := parseInterfaces(t, "iface eth0 inet manual\n")["eth0"]
+	metric := 5
+	manual.Metric = &metric
+	if err := manual.Validate(); !errors.Is(err, ErrWrongMethod) {
+		t.Errorf("Validate() with metric on manual = %v, want %v", err, ErrWrongMethod)
+	}
+
+	mp := NewMultiParser()
+	_, _ = mp.Write([]byte("iface eth0 inet carrier-pigeon\n"))
+	if _, err := mp.Parse(); !errors.Is(err, ErrInvalidIfaceData) {
+		t.Errorf("Parse() of an unknown method = %v, want %v", err, ErrInvalidIfaceData)
+	}
+}
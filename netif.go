@@ -1,13 +1,15 @@
 package ifupdown
 
 import (
-	"bufio"
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/netip"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -23,6 +25,12 @@ type Hooks struct {
 	PostDown []string `json:"post_down,omitempty"`
 }
 
+// Option is a single keyword and its value from an interface stanza.
+type Option struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
 type AddressConfig uint8
 
 const (
@@ -31,6 +39,15 @@ const (
 	AddressConfigDHCP
 	AddressConfigStatic
 	AddressConfigManual
+	// AddressConfigAuto configures inet6 by stateless autoconfiguration.
+	AddressConfigAuto
+	AddressConfigBootp
+	AddressConfigTunnel
+	AddressConfigPPP
+	AddressConfigWvdial
+	AddressConfigIPv4LL
+	AddressConfigV4Tunnel
+	AddressConfig6to4
 )
 
 var addressConfigMap = map[AddressConfig]string{
@@ -38,12 +55,30 @@ var addressConfigMap = map[AddressConfig]string{
 	AddressConfigDHCP:     "dhcp",
 	AddressConfigStatic:   "static",
 	AddressConfigManual:   "manual",
+	AddressConfigAuto:     "auto",
+	AddressConfigBootp:    "bootp",
+	AddressConfigTunnel:   "tunnel",
+	AddressConfigPPP:      "ppp",
+	AddressConfigWvdial:   "wvdial",
+	AddressConfigIPv4LL:   "ipv4ll",
+	AddressConfigV4Tunnel: "v4tunnel",
+	AddressConfig6to4:     "6to4",
 }
 
 func (ac AddressConfig) String() string {
 	return addressConfigMap[ac]
 }
 
+// parseAddressConfig returns the AddressConfig of the method called name.
+func parseAddressConfig(name string) (AddressConfig, bool) {
+	for config, method := range addressConfigMap {
+		if method == name {
+			return config, true
+		}
+	}
+	return AddressConfigUnset, false
+}
+
 type AddressVersion uint8
 
 const (
@@ -64,6 +99,10 @@ func (at AddressVersion) String() string {
 }
 
 // NetworkInterface follows the format of ifupdown /etc/network/interfaces
+//
+// Its methods are safe for concurrent use. Its fields may only be accessed
+// directly while it is not shared; use Update to modify, or Clone to take a
+// snapshot of, an interface other goroutines are using.
 type NetworkInterface struct {
 	// Name of the interface.
 	Name string `json:"name"`
@@ -71,6 +110,9 @@ type NetworkInterface struct {
 	Hotplug bool `json:"hotplug,omitempty"`
 	// Auto determines if the interface is automatically brought up.
 	Auto bool `json:"auto,omitempty"`
+	// Allow lists the allow-<class> classes of the interface other than
+	// auto and hotplug, which have fields of their own.
+	Allow []string `json:"allow,omitempty"`
 	// Address determines the static IP address of the interface.
 	Address net.IP `json:"address,omitempty"`
 
@@ -81,6 +123,8 @@ type NetworkInterface struct {
 	Gateway   net.IP         `json:"gateway,omitempty"`
 	Config    AddressConfig  `json:"config,omitempty"`
 	Version   AddressVersion `json:"version,omitempty"`
+	// Inherits names the stanza this one copies its options from.
+	Inherits string `json:"inherits,omitempty"`
 
 	// DNSServers of the interface.
 	DNSServers []net.IP `json:"dns_servers,omitempty"`
@@ -88,13 +132,44 @@ type NetworkInterface struct {
 	DNSSearch []string `json:"dns_search,omitempty"`
 	// MACAddress of the interface.
 	MACAddress net.HardwareAddr `json:"mac_address,omitempty"`
+	// Metric of the default route, for the static, dhcp and tunnel methods.
+	Metric *int `json:"metric,omitempty"`
+	// DHCP holds the options of the inet dhcp method.
+	DHCP *DHCP `json:"dhcp,omitempty"`
+	// Tunnel holds the options of the tunnel, v4tunnel and 6to4 methods.
+	Tunnel *Tunnel `json:"tunnel,omitempty"`
+	// PPP holds the options of the ppp and wvdial methods.
+	PPP *PPP `json:"ppp,omitempty"`
+	// Bootp holds the options of the bootp method.
+	Bootp *Bootp `json:"bootp,omitempty"`
+	// Scope of the address: global, site, link or host.
+	Scope string `json:"scope,omitempty"`
+	// IPv6 holds the options only inet6 stanzas have, if there are any.
+	IPv6 *IPv6 `json:"ipv6,omitempty"`
+	// Wireless holds the wpa-* and wireless-* options, if there are any.
+	Wireless *Wireless `json:"wireless,omitempty"`
 	// Hooks contains the pre/post up/down hooks.
 	Hooks Hooks `json:"hooks,omitempty"`
+	// Options holds the options of the stanza that have no dedicated field,
+	// in the order they appeared.
+	Options []Option `json:"options,omitempty"`
+	// Extensions holds the options of registered option families, keyed by
+	// the name of their family. See RegisterOptionFamily.
+	Extensions map[string]Extension `json:"-"`
+	// Dual is the stanza of the other address family of a dual-stack
+	// interface, such as the inet6 stanza that follows an inet one. It has
+	// the same Name and is written right after this stanza; the auto and
+	// allow-* classes of the interface are those of this stanza.
+	Dual *NetworkInterface `json:"dual,omitempty"`
 
 	dirty     bool
 	allocated bool
 	errs      []error
-	*sync.RWMutex
+	// reader holds the rendering Read is working through.
+	reader *bytes.Reader
+	// mu guards every field above. Exported methods take it themselves,
+	// unexported ones expect the caller to hold it.
+	mu sync.RWMutex
 }
 
 func NewNetworkInterface(name string) *NetworkInterface {
@@ -106,14 +181,112 @@ func NewNetworkInterface(name string) *NetworkInterface {
 	}
 }
 
-func (iface *NetworkInterface) allocate() {
-	if iface.RWMutex == nil {
-		iface.RWMutex = &sync.RWMutex{}
+// Clone returns a deep copy of iface that shares no memory with it. The copy
+// is a consistent snapshot: it can be read or modified without locking out,
+// or being affected by, goroutines still using the original.
+func (iface *NetworkInterface) Clone() *NetworkInterface {
+	iface.mu.RLock()
+	defer iface.mu.RUnlock()
+	clone := &NetworkInterface{
+		Name:       iface.Name,
+		Hotplug:    iface.Hotplug,
+		Auto:       iface.Auto,
+		Allow:      slices.Clone(iface.Allow),
+		Address:    slices.Clone(iface.Address),
+		Netmask:    slices.Clone(iface.Netmask),
+		Broadcast:  slices.Clone(iface.Broadcast),
+		Gateway:    slices.Clone(iface.Gateway),
+		Config:     iface.Config,
+		Version:    iface.Version,
+		Inherits:   iface.Inherits,
+		DNSSearch:  slices.Clone(iface.DNSSearch),
+		MACAddress: slices.Clone(iface.MACAddress),
+		Metric:     clonePtr(iface.Metric),
+		DHCP:       iface.DHCP.Clone(),
+		Tunnel:     iface.Tunnel.Clone(),
+		PPP:        iface.PPP.Clone(),
+		Bootp:      iface.Bootp.Clone(),
+		Scope:      iface.Scope,
+		IPv6:       iface.IPv6.Clone(),
+		Wireless:   iface.Wireless.Clone(),
+		Options:    slices.Clone(iface.Options),
+		Extensions: iface.cloneExtensions(),
+		Hooks: Hooks{
+			PreUp:    slices.Clone(iface.Hooks.PreUp),
+			PostUp:   slices.Clone(iface.Hooks.PostUp),
+			PreDown:  slices.Clone(iface.Hooks.PreDown),
+			PostDown: slices.Clone(iface.Hooks.PostDown),
+		},
+		dirty:     true,
+		allocated: iface.allocated,
+	}
+	if iface.DNSServers != nil {
+		clone.DNSServers = make([]net.IP, len(iface.DNSServers))
+		for i, ns := range iface.DNSServers {
+			clone.DNSServers[i] = slices.Clone(ns)
+		}
+	}
+	if iface.Dual != nil {
+		clone.Dual = iface.Dual.Clone()
+	}
+	return clone
+}
+
+// Update calls fn with iface locked for writing, so its fields can be changed
+// while it is shared between goroutines. fn must not call methods of iface.
+func (iface *NetworkInterface) Update(fn func(iface *NetworkInterface)) *NetworkInterface {
+	iface.allocate()
+	defer iface.mu.Unlock()
+	fn(iface)
+	return iface
+}
+
+// networkInterface has the fields of NetworkInterface without its methods,
+// so it can be handed to encoding/json without recursing.
+type networkInterface NetworkInterface
+
+// jsonInterface adds the extensions, which encoding/json cannot decode into
+// an interface type on its own.
+type jsonInterface struct {
+	*networkInterface
+	Extensions map[string]json.RawMessage `json:"extensions,omitempty"`
+}
+
+func (iface *NetworkInterface) MarshalJSON() ([]byte, error) {
+	iface.mu.RLock()
+	defer iface.mu.RUnlock()
+	extensions, err := iface.marshalExtensions()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonInterface{(*networkInterface)(iface), extensions})
+}
+
+func (iface *NetworkInterface) UnmarshalJSON(data []byte) error {
+	iface.mu.Lock()
+	defer iface.mu.Unlock()
+	aux := jsonInterface{networkInterface: (*networkInterface)(iface)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if err := iface.unmarshalExtensions(aux.Extensions); err != nil {
+		return err
 	}
-	iface.Lock() // gets unlocked in other methods
+	iface.touch()
+	return nil
+}
+
+// allocate locks iface for writing and marks it as modified.
+func (iface *NetworkInterface) allocate() {
+	iface.mu.Lock() // gets unlocked in other methods
+	iface.touch()
+}
+
+// touch marks iface as modified. The caller must hold the write lock.
+func (iface *NetworkInterface) touch() {
 	iface.dirty = true
 	iface.allocated = true
-
+	iface.reader = nil
 }
 
 func (iface *NetworkInterface) err() error {
@@ -134,19 +307,18 @@ func (iface *NetworkInterface) err() error {
 }
 
 func (iface *NetworkInterface) Validate() error {
+	iface.mu.Lock()
+	defer iface.mu.Unlock()
+	return iface.validate()
+}
+
+func (iface *NetworkInterface) validate() error {
 	if !iface.dirty && len(iface.errs) > 0 {
 		if err := iface.err(); err != nil {
 			return err
 		}
 	}
 
-	if iface.RWMutex == nil {
-		iface.RWMutex = &sync.RWMutex{}
-	}
-
-	iface.RLock()
-	defer iface.RUnlock()
-
 	iface.errs = iface.errs[:0]
 
 	if iface.allocated != true {
@@ -163,6 +335,10 @@ func (iface *NetworkInterface) Validate() error {
 		if iface.Address != nil {
 			iface.errs = append(iface.errs, ErrAddressSetWhenDHCP)
 		}
+	case AddressConfigAuto:
+		if iface.Address != nil {
+			iface.errs = append(iface.errs, ErrAddressSetWhenAuto)
+		}
 	case AddressConfigStatic:
 		switch {
 		case iface.Address == nil:
@@ -189,65 +365,85 @@ func (iface *NetworkInterface) Validate() error {
 		)
 	}
 
+	iface.errs = append(iface.errs, iface.validateFamily()...)
+	iface.errs = append(iface.errs, iface.validateMethod()...)
+	iface.errs = append(iface.errs, iface.validateRoutes()...)
+	iface.errs = append(iface.errs, iface.validateDual()...)
+
+	if err := iface.DHCP.Validate(); err != nil {
+		iface.errs = append(iface.errs, fmt.Errorf("[%s] %w", iface.Name, err))
+	}
+	if err := iface.Wireless.Validate(); err != nil {
+		iface.errs = append(iface.errs, fmt.Errorf("[%s] %w", iface.Name, err))
+	}
+
+	for _, name := range iface.extensionNames() {
+		if err := iface.Extensions[name].Validate(iface); err != nil {
+			iface.errs = append(iface.errs, fmt.Errorf("[%s] %w", name, err))
+		}
+	}
+
 	iface.dirty = false
 	return iface.err()
 }
 
 func (iface *NetworkInterface) WithAddress(address string) *NetworkInterface {
 	iface.allocate()
-	_, ipn, err := net.ParseCIDR(address)
-	if err == nil {
-		iface = iface.WithNetmask(ipn.Mask.Size())
+	if ip, ipn, err := net.ParseCIDR(address); err == nil {
+		iface.Address = ip
+		iface.Netmask = ipn.Mask
+		iface.mu.Unlock()
+		return iface
 	}
 	iface.Address = net.ParseIP(address)
 	if iface.Address == nil {
 		iface.errs = append(iface.errs, fmt.Errorf("invalid address: %s", address))
-		iface.Unlock()
+		iface.mu.Unlock()
 		return iface
 	}
-	iface.Unlock()
+	iface.mu.Unlock()
 	return iface
 }
 
 func (iface *NetworkInterface) WithLoopback() *NetworkInterface {
 	iface.allocate()
 	iface.Config = AddressConfigLoopback
-	iface.Unlock()
+	iface.mu.Unlock()
 	return iface
 }
 
 func (iface *NetworkInterface) WithDHCP() *NetworkInterface {
 	iface.allocate()
 	iface.Config = AddressConfigDHCP
-	iface.Unlock()
+	iface.mu.Unlock()
 	return iface
 }
 
 func (iface *NetworkInterface) WithStatic() *NetworkInterface {
 	iface.allocate()
 	iface.Config = AddressConfigStatic
-	iface.Unlock()
+	iface.mu.Unlock()
 	return iface
 }
 
 func (iface *NetworkInterface) WithManual() *NetworkInterface {
 	iface.allocate()
 	iface.Config = AddressConfigManual
-	iface.Unlock()
+	iface.mu.Unlock()
 	return iface
 }
 
 func (iface *NetworkInterface) WithAddressConfig(config AddressConfig) *NetworkInterface {
 	iface.allocate()
 	iface.Config = config
-	iface.Unlock()
+	iface.mu.Unlock()
 	return iface
 }
 
 func (iface *NetworkInterface) WithAddressVersion(version AddressVersion) *NetworkInterface {
 	iface.allocate()
 	iface.Version = version
-	iface.Unlock()
+	iface.mu.Unlock()
 	return iface
 }
 
@@ -262,13 +458,13 @@ func (iface *NetworkInterface) WithNetmask(mask, bits int) *NetworkInterface {
 		}
 		if parsedMask == nil {
 			iface.errs = append(iface.errs, fmt.Errorf("invalid mask: %d", mask))
-			iface.Unlock()
+			iface.mu.Unlock()
 			return iface
 		}
 	}
 	iface.Netmask = parsedMask
 	// iface.Netmask = netmask
-	iface.Unlock()
+	iface.mu.Unlock()
 	return iface
 }
 
@@ -277,10 +473,10 @@ func (iface *NetworkInterface) WithBroadcast(broadcast string) *NetworkInterface
 	iface.Broadcast = net.ParseIP(broadcast)
 	if iface.Broadcast == nil {
 		iface.errs = append(iface.errs, fmt.Errorf("invalid broadcast: %s", broadcast))
-		iface.Unlock()
+		iface.mu.Unlock()
 		return iface
 	}
-	iface.Unlock()
+	iface.mu.Unlock()
 	return iface
 }
 
@@ -289,24 +485,24 @@ func (iface *NetworkInterface) WithGateway(gateway string) *NetworkInterface {
 	iface.Gateway = net.ParseIP(gateway)
 	if iface.Gateway == nil {
 		iface.errs = append(iface.errs, fmt.Errorf("invalid gateway: %s", gateway))
-		iface.Unlock()
+		iface.mu.Unlock()
 		return iface
 	}
-	iface.Unlock()
+	iface.mu.Unlock()
 	return iface
 }
 
 func (iface *NetworkInterface) WithConfigMethod(config AddressConfig) *NetworkInterface {
 	iface.allocate()
 	iface.Config = config
-	iface.Unlock()
+	iface.mu.Unlock()
 	return iface
 }
 
 func (iface *NetworkInterface) WithVersion(version AddressVersion) *NetworkInterface {
 	iface.allocate()
 	iface.Version = version
-	iface.Unlock()
+	iface.mu.Unlock()
 	return iface
 }
 
@@ -319,14 +515,14 @@ func (iface *NetworkInterface) WithDNS(dnsServers []string) *NetworkInterface {
 			iface.errs = append(iface.errs, fmt.Errorf("invalid dns server: %s", dns))
 		}
 	}
-	iface.Unlock()
+	iface.mu.Unlock()
 	return iface
 }
 
 func (iface *NetworkInterface) WithDNSSearch(dnsSearch []string) *NetworkInterface {
 	iface.allocate()
 	iface.DNSSearch = dnsSearch
-	iface.Unlock()
+	iface.mu.Unlock()
 	return iface
 }
 
@@ -337,7 +533,7 @@ func (iface *NetworkInterface) WithMACAddress(macAddress string) *NetworkInterfa
 	if err != nil {
 		iface.errs = append(iface.errs, fmt.Errorf("invalid mac address: %s", macAddress))
 	}
-	iface.Unlock()
+	iface.mu.Unlock()
 	return iface
 }
 
@@ -346,9 +542,10 @@ func (iface *NetworkInterface) netMaskString(mask net.IPMask) string {
 		return ""
 	}
 	var m netip.Addr
-	if iface.Version == AddressVersion4 {
+	switch {
+	case iface.Version == AddressVersion4 && len(mask) >= net.IPv4len:
 		m = netip.AddrFrom4([4]byte{mask[0], mask[1], mask[2], mask[3]})
-	} else {
+	case len(mask) == net.IPv6len:
 		m = netip.AddrFrom16([16]byte{
 			mask[0], mask[1], mask[2], mask[3],
 			mask[4], mask[5], mask[6], mask[7],
@@ -362,16 +559,39 @@ func (iface *NetworkInterface) netMaskString(mask net.IPMask) string {
 	return m.String()
 }
 
-func (iface *NetworkInterface) String() string {
-	if iface.RWMutex == nil {
-		iface.RWMutex = new(sync.RWMutex)
+// parseNetmask parses the value of a netmask option, either a prefix length
+// or a mask in address form, for the address family version.
+func parseNetmask(s string, version AddressVersion) (net.IPMask, error) {
+	bits := 8 * net.IPv4len
+	if version == AddressVersion6 {
+		bits = 8 * net.IPv6len
 	}
-	iface.RLock()
-	defer iface.RUnlock()
+	if ones, err := strconv.Atoi(s); err == nil {
+		if ones < 0 || ones > bits {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMask, s)
+		}
+		return net.CIDRMask(ones, bits), nil
+	}
+	ip := net.ParseIP(s)
+	switch {
+	case ip == nil:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMask, s)
+	case bits == 8*net.IPv4len && ip.To4() != nil:
+		return net.IPMask(ip.To4()), nil
+	case bits == 8*net.IPv6len && ip.To4() == nil:
+		return net.IPMask(ip.To16()), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMask, s)
+	}
+}
+
+func (iface *NetworkInterface) String() string {
+	iface.mu.Lock()
+	defer iface.mu.Unlock()
 	if !iface.allocated {
 		return ""
 	}
-	if iface.Validate() != nil {
+	if iface.validate() != nil {
 		return ""
 	}
 	str := pools.Strs.Get()
@@ -389,325 +609,306 @@ func (iface *NetworkInterface) String() string {
 
 func (iface *NetworkInterface) Write(p []byte) (int, error) {
 	iface.allocate()
-	defer iface.Unlock()
-	xerox := bufio.NewScanner(bytes.NewReader(p))
-	numIfaces := strings.Count(string(p), "iface")
+	defer iface.mu.Unlock()
+	numIfaces := 0
+	lines := newLineReader(bytes.NewReader(p))
+	for {
+		line, ok := lines.next()
+		if !ok {
+			break
+		}
+		if keyword(normalize(line)) == "iface" {
+			numIfaces++
+		}
+	}
 	if numIfaces > 1 {
 		return 0, ErrMultipleInterfaces
 	}
-	for xerox.Scan() {
-		normalized := strings.TrimSpace(xerox.Text())
-		switch {
-		case strings.HasPrefix(normalized, "#"):
-			continue
-		case strings.HasPrefix(normalized, "auto"):
-			iface.Auto = true
+
+	lines = newLineReader(bytes.NewReader(p))
+	for {
+		line, ok := lines.next()
+		if !ok {
+			break
+		}
+		if err := iface.parseLine(line); err != nil {
+			return 0, err
+		}
+	}
+	if err := lines.err(); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// parseLine applies a single line of an interface stanza to iface.
+// The caller is responsible for locking.
+func (iface *NetworkInterface) parseLine(line string) error {
+	normalized := normalize(line)
+	key := keyword(normalized)
+
+	switch {
+	case normalized == "":
+		return nil
+	case isClassLine(key):
+		// a class line inside a single stanza, or one that names iface
+		class, _ := allowClass(key)
+		names := strings.Fields(normalized)[1:]
+		if iface.Name == "" || len(names) == 0 || slices.Contains(names, iface.Name) {
+			iface.allow(class)
+		}
+		return nil
+	case key == "iface":
+		return iface.parseIface(normalized)
+	case stanzaStart(key):
+		return nil
+	}
+
+	if parse, ok := optionParsers[key]; ok && iface.takes(key) {
+		return parse(iface, value(normalized))
+	}
+	if family := familyOf(key); family != nil {
+		return iface.parseExtension(family, key, value(normalized))
+	}
+	iface.Options = append(iface.Options, Option{Key: key, Value: value(normalized)})
+	return nil
+}
+
+// parseIface applies an iface line to iface.
+func (iface *NetworkInterface) parseIface(normalized string) error {
+	for i, fragment := range strings.Fields(normalized) {
+		switch i {
+		case 0:
 			continue
-		case strings.HasPrefix(normalized, "allow-hotplug"):
-			iface.Hotplug = true
-		case strings.HasPrefix(normalized, "iface"):
-			for i, fragment := range strings.Fields(normalized) {
-				// println(i, fragment)
-				switch i {
-				case 0:
-					if fragment != "iface" {
-						return 0, fmt.Errorf("%w: %s", ErrInvalidIfaceData, normalized)
-					}
-				case 1:
-					iface.Name = fragment
-				case 2:
-					switch fragment {
-					case "inet":
-						iface.Version = AddressVersion4
-						// println("version 4")
-					case "inet6":
-						iface.Version = AddressVersion6
-						// println("version 6")
-					default:
-					}
-				case 3:
-					switch fragment {
-					case "static":
-						iface.Config = AddressConfigStatic
-					case "dhcp":
-						iface.Config = AddressConfigDHCP
-					case "manual":
-						iface.Config = AddressConfigManual
-					case "loopback":
-						iface.Config = AddressConfigLoopback
-					default:
-						return 0, fmt.Errorf("%w: %s", ErrInvalidIfaceData, normalized)
-					}
-				default:
-					//
-				}
-			}
-		case strings.HasPrefix(normalized, "address"):
-			for i, fragment := range strings.Split(normalized, " ") {
-				switch i {
-				case 0:
-					continue
-				case 1:
-					if strings.Contains(fragment, "/") {
-						prfx, _ := netip.ParsePrefix(fragment)
-						iface.Address = net.ParseIP(prfx.Addr().String())
-						if iface.Address == nil {
-							return 0, ErrInvalidIfaceData
-						}
-						iface.Netmask = net.CIDRMask(prfx.Bits(), 8*len(iface.Address))
-						continue
-					}
-					iface.Address = net.ParseIP(fragment)
-					if iface.Address == nil {
-						return 0, ErrInvalidIfaceData
-					}
-				default:
-					//
-				}
-			}
-		case strings.HasPrefix(normalized, "netmask"):
-			if iface.Netmask != nil || iface.Version != AddressVersion4 {
-				continue
-			}
-			for i, fragment := range strings.Split(normalized, " ") {
-				switch i {
-				case 0:
-					continue
-				case 1:
-					maskBytes := net.ParseIP(fragment)
-					if maskBytes == nil {
-						return 0, ErrInvalidIfaceData
-					}
-					iface.Netmask = net.IPv4Mask(maskBytes[12], maskBytes[13], maskBytes[14], maskBytes[15])
-					if iface.Netmask == nil {
-						return 0, ErrInvalidIfaceData
-					}
-				default:
-					//
-				}
-			}
-		case strings.HasPrefix(normalized, "gateway"):
-			for i, fragment := range strings.Split(normalized, " ") {
-				switch i {
-				case 0:
-					continue
-				case 1:
-					iface.Gateway = net.ParseIP(fragment)
-					if iface.Gateway == nil {
-						return 0, ErrInvalidIfaceData
-					}
-				default:
-					//
-				}
+		case 1:
+			iface.Name = fragment
+		case 2:
+			switch fragment {
+			case "inet":
+				iface.Version = AddressVersion4
+			case "inet6":
+				iface.Version = AddressVersion6
+			default:
 			}
-		case strings.HasPrefix(normalized, "dns-nameservers"):
-			for i, fragment := range strings.Split(normalized, " ") {
-				switch i {
-				case 0:
-					continue
-				default:
-					iface.DNSServers = append(iface.DNSServers, net.ParseIP(fragment))
-				}
+		case 3:
+			config, ok := parseAddressConfig(fragment)
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrInvalidIfaceData, normalized)
 			}
-		case strings.HasPrefix(normalized, "dns-search"):
-			for i, fragment := range strings.Split(normalized, " ") {
-				switch i {
-				case 0:
-					continue
-				default:
-					iface.DNSSearch = append(iface.DNSSearch, fragment)
-				}
-			}
-		case strings.HasPrefix(normalized, "pre-up"):
-			hook := strings.TrimPrefix(normalized, "pre-up ")
-			if len(hook) == 0 {
-				continue
-			}
-			iface.Hooks.PreUp = append(iface.Hooks.PreUp, hook)
-		case strings.HasPrefix(normalized, "post-up"):
-			hook := strings.TrimPrefix(normalized, "post-up ")
-			if len(hook) == 0 {
-				continue
-			}
-			iface.Hooks.PostUp = append(iface.Hooks.PostUp, hook)
-		case strings.HasPrefix(normalized, "pre-down"):
-			hook := strings.TrimPrefix(normalized, "pre-down ")
-			if len(hook) == 0 {
-				continue
-			}
-			iface.Hooks.PreDown = append(iface.Hooks.PreDown, hook)
-		case strings.HasPrefix(normalized, "post-down"):
-			hook := strings.TrimPrefix(normalized, "post-down ")
-			if len(hook) == 0 {
-				continue
-			}
-			iface.Hooks.PostDown = append(iface.Hooks.PostDown, hook)
-		case strings.HasPrefix(normalized, "hwaddress"):
-			for i, fragment := range strings.Split(normalized, " ") {
-				switch i {
-				case 0:
-					continue
-				case 2:
-					var err error
-					iface.MACAddress, err = net.ParseMAC(fragment)
-					if err != nil {
-						return 0, ErrInvalidIfaceData
-					}
-					continue
-				default:
-					//
-				}
-
+			iface.Config = config
+		case 4:
+			if fragment != "inherits" {
+				return fmt.Errorf("%w: %s", ErrInvalidIfaceData, normalized)
 			}
+		case 5:
+			iface.Inherits = fragment
+		default:
 			//
 		}
 	}
+	return nil
+}
 
-	return len(p), nil
+// render validates iface and renders it into a new buffer.
+func (iface *NetworkInterface) render() ([]byte, error) {
+	if err := iface.validate(); err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := iface.write(func(s string) { buf.WriteString(s) }); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
+// Read reads the rendered interface into p. Consecutive calls continue where
+// the previous one stopped, so short buffers are fine. Once the whole stanza
+// has been read, Read returns io.EOF until the interface is modified.
 func (iface *NetworkInterface) Read(p []byte) (int, error) {
-	if err := iface.Validate(); err != nil {
-		return 0, err
+	iface.mu.Lock()
+	defer iface.mu.Unlock()
+	if iface.reader == nil {
+		data, err := iface.render()
+		if err != nil {
+			return 0, err
+		}
+		iface.reader = bytes.NewReader(data)
 	}
+	return iface.reader.Read(p)
+}
+
+// WriteTo renders the interface directly into w. It does not affect the
+// position of Read.
+func (iface *NetworkInterface) WriteTo(w io.Writer) (int64, error) {
+	iface.mu.Lock()
+	defer iface.mu.Unlock()
 	var (
-		count    = 0
-		wChan    = make(chan string)
-		errChan  = make(chan error)
-		doneChan = make(chan bool)
+		n    int64
+		werr error
 	)
-
-	w := func(s string) {
-		switch {
-		case len(s) == 0:
+	err := iface.write(func(s string) {
+		if werr != nil {
 			return
-		case len(s) > len(p),
-			count+len(s) > len(p):
-			errChan <- io.ErrShortBuffer
-		default:
-			wChan <- s
 		}
+		var m int
+		m, werr = io.WriteString(w, s)
+		n += int64(m)
+	})
+	if werr != nil {
+		return n, werr
 	}
-
-	go func() {
-		errChan <- iface.write(w)
-	}()
-
-	for {
-		select {
-		case <-doneChan:
-			return count, nil
-		case err := <-errChan:
-			if errors.Is(err, io.EOF) || err == nil {
-				return count, nil
-			}
-			if err != nil {
-				return count, err
-			}
-		case s := <-wChan:
-			count += copy(p[count:], s)
-		}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, err
 	}
+	return n, nil
 }
 
+// write renders iface through w. The caller must hold the write lock.
 func (iface *NetworkInterface) write(w func(s string)) error {
-	if err := iface.Validate(); err != nil {
+	if err := iface.validate(); err != nil {
 		return err
 	}
+	iface.writeClasses(w)
+	return iface.writeStanza(w)
+}
 
-	if iface.Auto {
-		w("auto ")
-		w(iface.Name)
-		w("\n")
-	}
-	if iface.Hotplug {
-		w("allow-hotplug ")
+// writeClasses writes the auto and allow-* lines of iface.
+func (iface *NetworkInterface) writeClasses(w func(s string)) {
+	for _, class := range iface.classes() {
+		w(classKeyword(class))
+		w(" ")
 		w(iface.Name)
 		w("\n")
 	}
+}
 
+// writeStanza writes the iface line and options of iface, and then those of
+// Dual after a blank line, without the lines of the classes it is in. The
+// caller must have validated iface.
+func (iface *NetworkInterface) writeStanza(w func(s string)) error {
 	w("iface ")
 	w(iface.Name)
 	w(" ")
 	w(iface.Version.String())
 	w(" ")
 	w(iface.Config.String())
+	if iface.Inherits != "" {
+		w(" inherits ")
+		w(iface.Inherits)
+	}
 	w("\n")
 
-	if (iface.Address != nil && iface.Netmask != nil && !iface.Address.IsUnspecified()) &&
-		(iface.Config == AddressConfigStatic || iface.Config == AddressConfigManual) {
-		w("\taddress ")
-		w(iface.Address.String())
+	for _, opt := range iface.options() {
+		w("\t")
+		w(opt.Key)
+		w(" ")
+		w(opt.Value)
+		if strings.HasSuffix(opt.Value, `\`) {
+			// keep the backslash from joining the next line
+			w(" ")
+		}
 		w("\n")
-		w("\tnetmask ")
-		w(iface.netMaskString(iface.Netmask))
+	}
+
+	if dual := iface.Dual; dual != nil && dual != iface {
 		w("\n")
+		dual.mu.RLock()
+		_ = dual.writeStanza(w)
+		dual.mu.RUnlock()
+	}
+	return io.EOF
+}
+
+// options lists the options of the stanza in the order they are written.
+// The caller must hold at least the read lock.
+func (iface *NetworkInterface) options() []Option {
+	var opts []Option
+	add := func(key, value string) {
+		opts = append(opts, Option{Key: key, Value: value})
+	}
+
+	if iface.Address != nil && !iface.Address.IsUnspecified() && iface.hasAddress() {
+		add("address", iface.Address.String())
+		if iface.Netmask != nil {
+			add("netmask", iface.netMaskString(iface.Netmask))
+		}
 		if iface.Broadcast != nil {
-			w("\tbroadcast ")
-			w(iface.Broadcast.String())
-			w("\n")
+			add("broadcast", iface.Broadcast.String())
 		}
 		if iface.Gateway != nil {
-			w("\tgateway ")
-			w(iface.Gateway.String())
-			w("\n")
+			add("gateway", iface.Gateway.String())
 		}
 	}
 
 	if len(iface.DNSServers) > 0 {
-		w("\tdns-nameservers")
-		for _, dns := range iface.DNSServers {
-			w(" ")
-			w(dns.String())
+		servers := make([]string, len(iface.DNSServers))
+		for i, dns := range iface.DNSServers {
+			servers[i] = dns.String()
 		}
-		w("\n")
+		add("dns-nameservers", strings.Join(servers, " "))
 	}
 
 	if len(iface.DNSSearch) > 0 {
-		w("\tdns-search")
-		for _, dns := range iface.DNSSearch {
-			w(" ")
-			w(dns)
-		}
-		w("\n")
+		add("dns-search", strings.Join(iface.DNSSearch, " "))
 	}
 
 	if iface.MACAddress != nil {
-		w("\thwaddress ether ")
-		w(iface.MACAddress.String())
-		w("\n")
+		add("hwaddress", "ether "+iface.MACAddress.String())
 	}
 
-	if len(iface.Hooks.PreUp) > 0 {
-		for _, hook := range iface.Hooks.PreUp {
-			w("\tpre-up ")
-			w(hook)
-			w("\n")
-		}
+	opts = append(opts, iface.methodSpecific()...)
+	opts = append(opts, iface.IPv6.options()...)
+	if iface.Scope != "" {
+		add("scope", iface.Scope)
 	}
+	opts = append(opts, iface.Wireless.options()...)
 
-	if len(iface.Hooks.PostUp) > 0 {
-		for _, hook := range iface.Hooks.PostUp {
-			w("\tpost-up ")
-			w(hook)
-			w("\n")
-		}
+	opts = append(opts, iface.Options...)
+	for _, name := range iface.extensionNames() {
+		opts = append(opts, iface.Extensions[name].Options()...)
 	}
 
-	if len(iface.Hooks.PreDown) > 0 {
-		for _, hook := range iface.Hooks.PreDown {
-			w("\tpre-down ")
-			w(hook)
-			w("\n")
-		}
+	for _, hook := range iface.Hooks.PreUp {
+		add("pre-up", hook)
+	}
+	for _, hook := range iface.Hooks.PostUp {
+		add("post-up", hook)
+	}
+	for _, hook := range iface.Hooks.PreDown {
+		add("pre-down", hook)
+	}
+	for _, hook := range iface.Hooks.PostDown {
+		add("post-down", hook)
 	}
 
-	if len(iface.Hooks.PostDown) > 0 {
-		for _, hook := range iface.Hooks.PostDown {
-			w("\tpost-down ")
-			w(hook)
-			w("\n")
+	return opts
+}
+
+// keyword returns the first field of line.
+func keyword(line string) string {
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// lookup returns the value of the last option called key, be it kept in
+// Options or by the extension of a registered option family.
+// The caller must hold at least the read lock.
+func (iface *NetworkInterface) lookup(key string) (string, bool) {
+	for i := len(iface.Options) - 1; i >= 0; i-- {
+		if iface.Options[i].Key == key {
+			return iface.Options[i].Value, true
 		}
 	}
-	return io.EOF
+	for _, name := range iface.extensionNames() {
+		opts := iface.Extensions[name].Options()
+		for i := len(opts) - 1; i >= 0; i-- {
+			if opts[i].Key == key {
+				return opts[i].Value, true
+			}
+		}
+	}
+	return "", false
 }
@@ -3,10 +3,15 @@ package ifupdown
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 )
 
 func TestAddressConfig_String(t *testing.T) {
@@ -392,3 +397,191 @@ iface eth0 inet static
 		})
 	}
 }
+
+func TestNetworkInterface_Read(t *testing.T) {
+	newIface := func() *NetworkInterface {
+		return NewNetworkInterface("eth0").
+			WithStatic().
+			WithAddressVersion(AddressVersion4).
+			WithAddress("10.0.0.5").
+			WithNetmask(24, 32).
+			WithGateway("10.0.0.1").
+			WithDNS([]string{"1.1.1.1", "9.9.9.9"})
+	}
+
+	t.Run("iotest", func(t *testing.T) {
+		iface := newIface()
+		if err := iotest.TestReader(iface, []byte(iface.String())); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("short buffers", func(t *testing.T) {
+		iface := newIface()
+		before := runtime.NumGoroutine()
+		for i := 0; i < 100; i++ {
+			n, err := iface.Read(make([]byte, 1))
+			if n != 1 || err != nil {
+				t.Fatalf("Read() = %d, %v", n, err)
+			}
+		}
+		if after := runtime.NumGoroutine(); after > before {
+			t.Errorf("Read() leaked goroutines: %d before, %d after", before, after)
+		}
+		rest, err := io.ReadAll(iface)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(rest); got != iface.String()[100:] {
+			t.Errorf("ReadAll() after partial reads = %q, want %q", got, iface.String()[100:])
+		}
+	})
+
+	t.Run("reset on modification", func(t *testing.T) {
+		iface := newIface()
+		if _, err := io.ReadAll(iface); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := iface.Read(make([]byte, 10)); n != 0 || !errors.Is(err, io.EOF) {
+			t.Fatalf("Read() at EOF = %d, %v", n, err)
+		}
+		iface = iface.WithGateway("10.0.0.254")
+		dat, err := io.ReadAll(iface)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(dat), "gateway 10.0.0.254") {
+			t.Errorf("Read() after modification = %q", dat)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := NewNetworkInterface("eth0").Read(make([]byte, 64)); err == nil {
+			t.Error("Read() of an invalid interface succeeded")
+		}
+	})
+
+	t.Run("WriteTo", func(t *testing.T) {
+		iface := newIface()
+		buf := &bytes.Buffer{}
+		n, err := io.Copy(buf, iface)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(iface.String())) || buf.String() != iface.String() {
+			t.Errorf("WriteTo() = %d %q, want %q", n, buf.String(), iface.String())
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		wg := &sync.WaitGroup{}
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				iface := newIface()
+				dat, err := io.ReadAll(iotest.HalfReader(iface))
+				if err != nil || string(dat) != iface.String() {
+					t.Errorf("ReadAll() = %q, %v", dat, err)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func TestInterfaces_WriteTo(t *testing.T) {
+	ifaces := testInterfaces("10.0.0.5")
+	buf := &bytes.Buffer{}
+	n, err := ifaces.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) || buf.String() != ifaces.String() {
+		t.Errorf("WriteTo() = %d %q, want %q", n, buf.String(), ifaces.String())
+	}
+}
+
+func TestNetworkInterface_Concurrent(t *testing.T) {
+	iface := NewNetworkInterface("eth0").
+		WithStatic().
+		WithAddressVersion(AddressVersion4).
+		WithAddress("10.0.0.5/24")
+
+	wg := &sync.WaitGroup{}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				switch (g + i) % 8 {
+				case 0:
+					iface.WithGateway(fmt.Sprintf("10.0.0.%d", i%250+1))
+				case 1:
+					_ = iface.String()
+				case 2:
+					_ = iface.Validate()
+				case 3:
+					_, _ = io.Copy(io.Discard, iface)
+				case 4:
+					_, _ = iface.Read(make([]byte, 16))
+				case 5:
+					snap := iface.Clone()
+					if err := snap.Validate(); err != nil {
+						t.Errorf("snapshot Validate() = %v", err)
+					}
+				case 6:
+					if _, err := json.Marshal(iface); err != nil {
+						t.Errorf("json.Marshal() = %v", err)
+					}
+				case 7:
+					iface.Update(func(iface *NetworkInterface) {
+						iface.DNSSearch = []string{"example.com"}
+					})
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if err := iface.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestNetworkInterface_Clone(t *testing.T) {
+	iface := NewNetworkInterface("eth0").
+		WithStatic().
+		WithAddressVersion(AddressVersion4).
+		WithAddress("10.0.0.5/24").
+		WithDNS([]string{"1.1.1.1"})
+	iface.Hooks.PreUp = []string{"echo yeet"}
+
+	clone := iface.Clone()
+	clone.WithAddress("10.0.0.6/24")
+	clone.DNSServers[0][15] = 2
+	clone.Hooks.PreUp[0] = "echo yeeted"
+
+	if iface.Address.String() != "10.0.0.5" || iface.DNSServers[0].String() != "1.1.1.1" ||
+		iface.Hooks.PreUp[0] != "echo yeet" {
+		t.Errorf("modifying a clone changed the original:\n%s", iface)
+	}
+	if clone.String() == iface.String() {
+		t.Errorf("clone was not modified:\n%s", clone)
+	}
+}
+
+func TestNetworkInterface_UnmarshalJSON(t *testing.T) {
+	iface := &NetworkInterface{}
+	if err := json.Unmarshal([]byte(`{"name":"eth0","config":2,"version":1}`), iface); err != nil {
+		t.Fatal(err)
+	}
+	if err := iface.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	// the zero value mutex must be usable straight after decoding
+	iface.WithGateway("10.0.0.1")
+	if !strings.Contains(iface.String(), "iface eth0 inet dhcp") {
+		t.Errorf("String() = %q", iface.String())
+	}
+}
@@ -0,0 +1,345 @@
+package ifupdown
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/netip"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Rule is a policy routing rule, added by an "ip rule add" hook.
+type Rule struct {
+	// Version is the family ip was told with -4 or -6, if any.
+	Version AddressVersion `json:"version,omitempty"`
+	// From selects the source prefix. It is not valid for "from all".
+	From netip.Prefix `json:"from,omitempty"`
+	// To selects the destination prefix, if the rule has one.
+	To netip.Prefix `json:"to,omitempty"`
+	// IIF and OIF select the incoming and outgoing device.
+	IIF string `json:"iif,omitempty"`
+	OIF string `json:"oif,omitempty"`
+	// FWMark selects a firewall mark, with an optional /mask.
+	FWMark string `json:"fwmark,omitempty"`
+	// Priority of the rule, 0 to let the kernel pick one.
+	Priority int `json:"priority,omitempty"`
+	// Table the rule looks routes up in, by name or number.
+	Table string `json:"table"`
+}
+
+// Policy is the policy routing of an interface: its rules and the routes it
+// adds to tables other than main.
+type Policy struct {
+	Rules  []Rule  `json:"rules,omitempty"`
+	Routes []Route `json:"routes,omitempty"`
+}
+
+// ParseRule recognises a hook that adds a policy routing rule that looks up a
+// table. It reports false for any other command, including rules with other
+// actions such as blackhole.
+func ParseRule(command string) (Rule, bool) {
+	r, verb, ok := parseRuleCommand(command)
+	return r, ok && verb == "add"
+}
+
+// parseRuleCommand recognises a hook that adds or deletes a rule, and returns
+// which of the two, add or del, it does.
+func parseRuleCommand(command string) (Rule, string, bool) {
+	args, ok := commandArgs(command)
+	if !ok || program(args[0]) != "ip" {
+		return Rule{}, "", false
+	}
+	family, args, ok := ipFamily(args[1:])
+	if !ok || len(args) < 2 || !isObject(args[0], "rule") || len(args[0]) < 2 || ipVerb(args[1]) == "" {
+		// "ip r" is ip route, not ip rule
+		return Rule{}, "", false
+	}
+	verb := ipVerb(args[1])
+
+	var r Rule
+	switch family {
+	case 4:
+		r.Version = AddressVersion4
+	case 6:
+		r.Version = AddressVersion6
+	}
+	args = args[2:]
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			return Rule{}, "", false
+		}
+		key, value := args[i], args[i+1]
+		i++
+		var err error
+		switch key {
+		case "from":
+			if value != "all" {
+				r.From, err = parseRulePrefix(value)
+			}
+		case "to":
+			if value != "all" {
+				r.To, err = parseRulePrefix(value)
+			}
+		case "iif", "dev":
+			r.IIF = value
+		case "oif":
+			r.OIF = value
+		case "fwmark":
+			r.FWMark = value
+		case "priority", "pref", "prio":
+			r.Priority, err = strconv.Atoi(value)
+		case "table", "lookup":
+			r.Table = value
+		default:
+			return Rule{}, "", false
+		}
+		if err != nil {
+			return Rule{}, "", false
+		}
+	}
+	if r.Table == "" {
+		return Rule{}, "", false
+	}
+	return r, verb, true
+}
+
+// parseRulePrefix parses a prefix or a single address of a rule.
+func parseRulePrefix(s string) (netip.Prefix, error) {
+	if prefix, err := netip.ParsePrefix(s); err == nil {
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// selector renders what follows "ip rule add" for r.
+func (r Rule) selector() string {
+	var b strings.Builder
+	prefix := func(p netip.Prefix) string {
+		if p.IsSingleIP() {
+			return p.Addr().String()
+		}
+		return p.String()
+	}
+	if r.From.IsValid() {
+		b.WriteString(" from " + prefix(r.From))
+	} else {
+		b.WriteString(" from all")
+	}
+	if r.To.IsValid() {
+		b.WriteString(" to " + prefix(r.To))
+	}
+	if r.IIF != "" {
+		b.WriteString(" iif " + r.IIF)
+	}
+	if r.OIF != "" {
+		b.WriteString(" oif " + r.OIF)
+	}
+	if r.FWMark != "" {
+		b.WriteString(" fwmark " + r.FWMark)
+	}
+	if r.Priority != 0 {
+		b.WriteString(" priority " + strconv.Itoa(r.Priority))
+	}
+	b.WriteString(" table " + r.Table)
+	return b.String()
+}
+
+// ipFlag returns -6 for rules that need it: ip rule works on IPv4 unless told
+// otherwise.
+func (r Rule) ipFlag() string {
+	if r.Version == AddressVersion6 || r.From.Addr().Is6() || r.To.Addr().Is6() {
+		return "-6 "
+	}
+	return ""
+}
+
+// Command returns the hook that adds r.
+func (r Rule) Command() string {
+	return "ip " + r.ipFlag() + "rule add" + r.selector()
+}
+
+// DelCommand returns the hook that removes r again.
+func (r Rule) DelCommand() string {
+	return "ip " + r.ipFlag() + "rule del" + r.selector()
+}
+
+// Policy returns the rules the up hooks of iface add, and the routes it adds
+// to tables other than main.
+func (iface *NetworkInterface) Policy() Policy {
+	iface.mu.RLock()
+	defer iface.mu.RUnlock()
+	return iface.policy()
+}
+
+func (iface *NetworkInterface) policy() Policy {
+	var p Policy
+	for _, hooks := range [][]string{iface.Hooks.PreUp, iface.Hooks.PostUp} {
+		for _, hook := range hooks {
+			if r, ok := ParseRule(hook); ok {
+				p.Rules = append(p.Rules, r)
+			}
+		}
+	}
+	for _, r := range iface.routes() {
+		if r.Table != "" && r.Table != "main" && r.Table != "254" {
+			p.Routes = append(p.Routes, r)
+		}
+	}
+	return p
+}
+
+// Tables returns the tables the policy refers to, in the order they first
+// appear.
+func (p Policy) Tables() []string {
+	var tables []string
+	for _, r := range p.Rules {
+		if !slices.Contains(tables, r.Table) {
+			tables = append(tables, r.Table)
+		}
+	}
+	for _, r := range p.Routes {
+		if !slices.Contains(tables, r.Table) {
+			tables = append(tables, r.Table)
+		}
+	}
+	return tables
+}
+
+// RouteTables maps the names of routing tables to their number, as
+// rt_tables does.
+type RouteTables map[string]int
+
+// reservedTables are the tables iproute2 knows without rt_tables.
+var reservedTables = RouteTables{"unspec": 0, "default": 253, "main": 254, "local": 255}
+
+// ParseRouteTables reads the rt_tables file name from fsys, along with the
+// *.conf files in the name.d directory next to it if there is one. The
+// reserved tables are always included.
+func ParseRouteTables(fsys fs.FS, name string) (RouteTables, error) {
+	name = fsPath(name)
+	tables := make(RouteTables)
+	for table, id := range reservedTables {
+		tables[table] = id
+	}
+
+	files := []string{name}
+	extra, err := fs.Glob(fsys, path.Join(name+".d", "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, extra...)
+
+	for _, file := range files {
+		f, err := fsys.Open(file)
+		if err != nil {
+			if file != name && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		lines := bufio.NewScanner(f)
+		for n := 1; lines.Scan(); n++ {
+			fields := strings.Fields(lines.Text())
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			id, err := strconv.ParseUint(fields[0], 0, 32)
+			if len(fields) < 2 || err != nil {
+				_ = f.Close()
+				return nil, fmt.Errorf("%w: %s:%d: %s", ErrInvalidRouteTables, file, n, lines.Text())
+			}
+			tables[fields[1]] = int(id)
+		}
+		err = lines.Err()
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tables, nil
+}
+
+// Has reports whether table is defined, or is a number ip accepts as is.
+func (t RouteTables) Has(table string) bool {
+	if _, ok := t[table]; ok {
+		return true
+	}
+	id, err := strconv.ParseUint(table, 10, 32)
+	return err == nil && id > 0
+}
+
+// CheckTables checks that every table the policy routing of iface refers to
+// is defined in tables.
+func (iface *NetworkInterface) CheckTables(tables RouteTables) error {
+	var errs []error
+	for _, table := range iface.Policy().Tables() {
+		if !tables.Has(table) {
+			errs = append(errs, fmt.Errorf("[%s] %w: %s", iface.Name, ErrUnknownTable, table))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckTables checks the tables of every interface in i.
+func (i Interfaces) CheckTables(tables RouteTables) error {
+	var errs []error
+	for _, name := range i.names() {
+		if i[name] == nil {
+			continue
+		}
+		if err := i[name].CheckTables(tables); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PolicyCleanup returns the pre-down hooks that remove the rules and table
+// routes of iface, newest first, leaving out those its down hooks already
+// remove.
+func (iface *NetworkInterface) PolicyCleanup() []string {
+	iface.mu.RLock()
+	defer iface.mu.RUnlock()
+	return iface.policyCleanup()
+}
+
+func (iface *NetworkInterface) policyCleanup() []string {
+	// match down hooks the same way MissingTeardowns does, so that the two
+	// never disagree about what is already removed
+	removed := iface.removedKeys()
+	var cleanup []string
+	remove := func(command string) {
+		action, ok := iface.hookAction(command)
+		if !ok || removed[action.key] {
+			return
+		}
+		removed[action.key] = true
+		cleanup = append(cleanup, action.inverse)
+	}
+
+	p := iface.policy()
+	for i := len(p.Rules) - 1; i >= 0; i-- {
+		remove(p.Rules[i].Command())
+	}
+	for i := len(p.Routes) - 1; i >= 0; i-- {
+		remove(p.Routes[i].Command())
+	}
+	return cleanup
+}
+
+// WithPolicyCleanup adds the hooks PolicyCleanup returns to the pre-down
+// hooks of the interface.
+func (iface *NetworkInterface) WithPolicyCleanup() *NetworkInterface {
+	iface.allocate()
+	iface.Hooks.PreDown = append(iface.Hooks.PreDown, iface.policyCleanup()...)
+	iface.mu.Unlock()
+	return iface
+}
@@ -0,0 +1,165 @@
+package ifupdown
+
+import (
+	"errors"
+	"net/netip"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseRule(t *testing.T) {
+	for command, want := range map[string]Rule{
+		"ip rule add from 10.0.0.2 table 10": {
+			From:  netip.MustParsePrefix("10.0.0.2/32"),
+			Table: "10",
+		},
+		"/sbin/ip -6 ru add from 2001:db8::/64 to all iif eth1 pref 100 lookup isp2 || true": {
+			Version:  AddressVersion6,
+			From:     netip.MustParsePrefix("2001:db8::/64"),
+			IIF:      "eth1",
+			Priority: 100,
+			Table:    "isp2",
+		},
+		"ip rule add fwmark 0x1/0xff to 192.0.2.0/24 table dmz": {
+			To:     netip.MustParsePrefix("192.0.2.0/24"),
+			FWMark: "0x1/0xff",
+			Table:  "dmz",
+		},
+	} {
+		got, ok := ParseRule(command)
+		if !ok || got != want {
+			t.Errorf("ParseRule(%q) = %+v, %v, want %+v", command, got, ok, want)
+		}
+		if again, _ := ParseRule(got.Command()); again.From != got.From || again.Table != got.Table {
+			t.Errorf("%q does not parse back: %+v", got.Command(), again)
+		}
+	}
+
+	for _, command := range []string{
+		"ip rule del from 10.0.0.2 table 10",
+		"ip r add from 10.0.0.2 table 10",
+		"ip rule add from 10.0.0.2 blackhole",
+		"ip rule add from 10.0.0.2 table",
+		"ip rule add from somewhere table 10",
+		"ip route add 10.1.0.0/16 via 10.0.0.253 table 10",
+	} {
+		if r, ok := ParseRule(command); ok {
+			t.Errorf("ParseRule(%q) = %+v, want no rule", command, r)
+		}
+	}
+}
+
+func TestRule_Command(t *testing.T) {
+	r, _ := ParseRule("ip rule add from 2001:db8::2 priority 10 table isp2")
+	if got, want := r.Command(), "ip -6 rule add from 2001:db8::2 priority 10 table isp2"; got != want {
+		t.Errorf("Command() = %q, want %q", got, want)
+	}
+	if got, want := r.DelCommand(), "ip -6 rule del from 2001:db8::2 priority 10 table isp2"; got != want {
+		t.Errorf("DelCommand() = %q, want %q", got, want)
+	}
+}
+
+const policyFile = `iface eth1 inet static
+	address 198.51.100.2/24
+	post-up ip route add default via 198.51.100.1 table isp2
+	post-up ip route add 198.51.100.0/24 dev eth1 table isp2
+	post-up ip route add 10.9.0.0/16 via 198.51.100.9
+	post-up ip rule add from 198.51.100.2 table isp2
+	post-up ip rule add fwmark 2 table 200
+	pre-down ip rule del fwmark 2 table 200
+`
+
+func TestNetworkInterface_Policy(t *testing.T) {
+	eth1 := parseInterfaces(t, policyFile)["eth1"]
+	p := eth1.Policy()
+	if len(p.Rules) != 2 || len(p.Routes) != 2 {
+		t.Fatalf("Policy() = %+v, want 2 rules and 2 table routes", p)
+	}
+	if got := p.Tables(); !slices.Equal(got, []string{"isp2", "200"}) {
+		t.Errorf("Tables() = %q", got)
+	}
+
+	want := []string{
+		"ip rule del from 198.51.100.2 table isp2",
+		"ip route del 198.51.100.0/24 dev eth1 table isp2",
+		"ip route del default via 198.51.100.1 table isp2",
+	}
+	if got := eth1.PolicyCleanup(); !slices.Equal(got, want) {
+		t.Errorf("PolicyCleanup() = %q, want %q", got, want)
+	}
+	eth1.WithPolicyCleanup()
+	if got := eth1.Hooks.PreDown; len(got) != 4 || !slices.Equal(got[1:], want) {
+		t.Errorf("pre-down after WithPolicyCleanup() = %q", got)
+	}
+	if got := eth1.PolicyCleanup(); len(got) != 0 {
+		t.Errorf("PolicyCleanup() after WithPolicyCleanup() = %q", got)
+	}
+}
+
+func TestNetworkInterface_PolicyCleanup_IFACE(t *testing.T) {
+	eth1 := parseInterfaces(t, `iface eth1 inet manual
+	post-up ip rule add iif $IFACE table isp2
+	pre-down ip rule del iif eth1 table isp2
+`)["eth1"]
+	if got := eth1.PolicyCleanup(); len(got) != 0 {
+		t.Errorf("PolicyCleanup() = %q", got)
+	}
+}
+
+func TestNetworkInterface_PolicyCleanup_Teardowns(t *testing.T) {
+	eth1 := parseInterfaces(t, `iface eth1 inet manual
+	post-up ip rule add from 198.51.100.2 table isp2
+	post-up ip route add default via 198.51.100.1 table isp2
+	post-up ip route add 198.51.100.0/24 dev eth1 table isp2
+	pre-down ip -4 rule del from 198.51.100.2/32 lookup isp2
+	pre-down ip route del default table isp2
+`)["eth1"]
+	want := []string{"ip route del 198.51.100.0/24 dev eth1 table isp2"}
+	if got := eth1.PolicyCleanup(); !slices.Equal(got, want) {
+		t.Errorf("PolicyCleanup() = %q, want %q", got, want)
+	}
+	missing := eth1.MissingTeardowns()
+	if len(missing) != 1 || missing[0].Inverse != want[0] {
+		t.Errorf("MissingTeardowns() = %+v, want only %q", missing, want[0])
+	}
+
+	eth1.WithTeardowns()
+	if got := eth1.PolicyCleanup(); len(got) != 0 {
+		t.Errorf("PolicyCleanup() after WithTeardowns() = %q", got)
+	}
+}
+
+func TestParseRouteTables(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/iproute2/rt_tables":             {Data: []byte("# reserved values\n255\tlocal\n254\tmain\n\n100 isp1\n")},
+		"etc/iproute2/rt_tables.d/isp2.conf": {Data: []byte("0x65 isp2 # second uplink\n")},
+		"etc/iproute2/rt_tables.d/README":    {Data: []byte("not a table\n")},
+	}
+	tables, err := ParseRouteTables(fsys, "/etc/iproute2/rt_tables")
+	if err != nil {
+		t.Fatalf("ParseRouteTables() = %v", err)
+	}
+	for name, id := range map[string]int{"isp1": 100, "isp2": 101, "main": 254, "default": 253} {
+		if tables[name] != id {
+			t.Errorf("table %s = %d, want %d", name, tables[name], id)
+		}
+	}
+
+	ifaces := parseInterfaces(t, policyFile)
+	if err := ifaces.CheckTables(tables); err != nil {
+		t.Errorf("CheckTables() = %v", err)
+	}
+	delete(tables, "isp2")
+	if err := ifaces.CheckTables(tables); !errors.Is(err, ErrUnknownTable) {
+		t.Errorf("CheckTables() without isp2 = %v, want %v", err, ErrUnknownTable)
+	}
+
+	fsys["etc/iproute2/rt_tables.d/bad.conf"] = &fstest.MapFile{Data: []byte("isp3 102\n")}
+	if _, err := ParseRouteTables(fsys, "etc/iproute2/rt_tables"); !errors.Is(err, ErrInvalidRouteTables) {
+		t.Errorf("ParseRouteTables() with a bad line = %v, want %v", err, ErrInvalidRouteTables)
+	}
+	if _, err := ParseRouteTables(fstest.MapFS{}, "etc/iproute2/rt_tables"); err == nil {
+		t.Error("ParseRouteTables() without rt_tables succeeded")
+	}
+}
@@ -0,0 +1,631 @@
+package ifupdown
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// RouteStyle is the way a route is written in an interfaces file.
+type RouteStyle string
+
+const (
+	// RouteStyleIP is an "up ip route add ..." hook.
+	RouteStyleIP RouteStyle = "ip"
+	// RouteStyleNetTools is an "up route add ..." hook, for net-tools.
+	RouteStyleNetTools RouteStyle = "route"
+	// RouteStyleOption is a "route ..." option, as ifupdown2 style
+	// configurations have, taking the arguments of ip route add.
+	RouteStyleOption RouteStyle = "option"
+)
+
+// routeOption is the keyword of routes written in RouteStyleOption.
+const routeOption = "route"
+
+// Route is a static route of an interface, recognised from its hooks or its
+// route options.
+type Route struct {
+	// Destination of the route, 0.0.0.0/0 or ::/0 for a default route.
+	Destination netip.Prefix `json:"destination"`
+	// Via is the gateway, if the route has one.
+	Via netip.Addr `json:"via,omitempty"`
+	// Src is the preferred source address, if the route has one.
+	Src netip.Addr `json:"src,omitempty"`
+	// Dev is the device, often $IFACE, if the route names one.
+	Dev string `json:"dev,omitempty"`
+	// Metric of the route, 0 if it has none.
+	Metric int `json:"metric,omitempty"`
+	// Table is the routing table, by name or number, if not main.
+	Table string `json:"table,omitempty"`
+	// OnLink makes the gateway reachable even if no route covers it.
+	OnLink bool `json:"onlink,omitempty"`
+	// Proto is the routing protocol the route is marked with, such as static.
+	Proto string `json:"proto,omitempty"`
+	// Scope of the destination, such as link, if the route sets one.
+	Scope string `json:"scope,omitempty"`
+	// MTU of the path, 0 if the route does not set one.
+	MTU int `json:"mtu,omitempty"`
+	// Style is the way the route was written.
+	Style RouteStyle `json:"style,omitempty"`
+}
+
+// commandArgs splits a hook into its arguments, taking quotes and backslashes
+// off the way the shell does. It ignores a trailing "|| true", which is often
+// used to keep a failing route from failing the interface, and gives up on
+// any other shell syntax, including $ inside quotes. A $ outside of quotes is
+// kept, so that $IFACE stays in the arguments as it was written.
+func commandArgs(command string) ([]string, bool) {
+	command, _ = cutOrTrue(command)
+	var (
+		args  []string
+		arg   strings.Builder
+		inArg bool
+		quote byte
+	)
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote != 0:
+			switch {
+			case c == quote:
+				quote = 0
+			case c == '$', c == '`', c == '\\' && quote == '"':
+				return nil, false
+			default:
+				arg.WriteByte(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inArg = c, true
+		case c == '\\':
+			if i+1 >= len(command) {
+				return nil, false
+			}
+			i++
+			arg.WriteByte(command[i])
+			inArg = true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case strings.IndexByte("|&;<>()`#", c) >= 0:
+			return nil, false
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, false
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, len(args) > 0
+}
+
+// cutOrTrue returns command without a trailing "|| true", and whether it
+// had one.
+func cutOrTrue(command string) (string, bool) {
+	rest, found := strings.CutSuffix(strings.TrimRight(command, " \t"), "true")
+	if !found {
+		return command, false
+	}
+	rest, found = strings.CutSuffix(strings.TrimRight(rest, " \t"), "||")
+	if !found || len(rest) == 0 {
+		return command, false
+	}
+	return strings.TrimRight(rest, " \t"), true
+}
+
+// quoteArg quotes arg for the shell if it needs it, so that commandArgs gives
+// it back. A $ is left alone: commandArgs only keeps it outside of quotes.
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\|&;<>()`#*?[]~!{}") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// quoteArgs joins args into a command line, quoting them as needed.
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteArg(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// program returns the name of the program arg runs, without its directory.
+func program(arg string) string {
+	return arg[strings.LastIndexByte(arg, '/')+1:]
+}
+
+// ParseRoute recognises a hook that adds a route, either with ip route or with
+// the route command of net-tools. It reports false for any other command.
+func ParseRoute(command string) (Route, bool) {
+	r, verb, ok := parseRouteCommand(command)
+	return r, ok && verb == "add"
+}
+
+// parseRouteCommand recognises a hook that adds or deletes a route, and
+// returns which of the two, add or del, it does.
+func parseRouteCommand(command string) (Route, string, bool) {
+	args, ok := commandArgs(command)
+	if !ok {
+		return Route{}, "", false
+	}
+	switch program(args[0]) {
+	case "ip":
+		return parseIPRoute(args[1:])
+	case "route":
+		return parseNetToolsRoute(args[1:])
+	default:
+		return Route{}, "", false
+	}
+}
+
+// ipFamily takes the -4 or -6 flag off the arguments of ip, and returns the
+// family it asks for, 0 if none.
+func ipFamily(args []string) (int, []string, bool) {
+	family := 0
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "-4":
+			family = 4
+		case "-6":
+			family = 6
+		default:
+			return 0, nil, false
+		}
+		args = args[1:]
+	}
+	return family, args, true
+}
+
+// ipVerb returns add for the verbs of ip that add an object and del for
+// the ones that delete it.
+func ipVerb(arg string) string {
+	switch arg {
+	case "add", "replace":
+		return "add"
+	case "del", "delete":
+		return "del"
+	default:
+		return ""
+	}
+}
+
+// parseIPRoute parses the arguments of ip after its name.
+func parseIPRoute(args []string) (Route, string, bool) {
+	family, args, ok := ipFamily(args)
+	if !ok || len(args) < 3 || !isObject(args[0], "route") || ipVerb(args[1]) == "" {
+		return Route{}, "", false
+	}
+	r, ok := parseRouteArgs(args[2:], family)
+	r.Style = RouteStyleIP
+	return r, ipVerb(args[1]), ok
+}
+
+// isObject reports whether arg is object or one of the abbreviations ip takes.
+func isObject(arg, object string) bool {
+	return arg != "" && strings.HasPrefix(object, arg)
+}
+
+// parseRouteArgs parses what follows "ip route add": the destination, then
+// keyword and value pairs. family is 4 or 6 if ip was told, 0 otherwise.
+func parseRouteArgs(args []string, family int) (Route, bool) {
+	if len(args) == 0 {
+		return Route{}, false
+	}
+	var r Route
+	dst, ok := parseDestination(args[0], family)
+	if !ok {
+		return Route{}, false
+	}
+	r.Destination = dst
+
+	for i := 1; i < len(args); i++ {
+		key := args[i]
+		if key == "onlink" {
+			r.OnLink = true
+			continue
+		}
+		if i+1 >= len(args) {
+			return Route{}, false
+		}
+		value := args[i+1]
+		i++
+		var err error
+		switch key {
+		case "via":
+			r.Via, err = netip.ParseAddr(value)
+		case "src":
+			r.Src, err = netip.ParseAddr(value)
+		case "dev":
+			r.Dev = value
+		case "metric":
+			r.Metric, err = strconv.Atoi(value)
+		case "table":
+			r.Table = value
+		case "proto", "protocol":
+			r.Proto = value
+		case "scope":
+			r.Scope = value
+		case "mtu":
+			r.MTU, err = strconv.Atoi(value)
+		default:
+			return Route{}, false
+		}
+		if err != nil {
+			return Route{}, false
+		}
+	}
+	if family == 0 && r.Destination.Bits() == 0 && r.Via.Is6() {
+		// like ip, take the family of a default route from its gateway
+		r.Destination = netip.PrefixFrom(netip.IPv6Unspecified(), 0)
+	}
+	return r, true
+}
+
+// parseDestination parses the destination of a route: a prefix, a single
+// address or default.
+func parseDestination(s string, family int) (netip.Prefix, bool) {
+	if s == "default" {
+		if family == 6 {
+			return netip.PrefixFrom(netip.IPv6Unspecified(), 0), true
+		}
+		return netip.PrefixFrom(netip.IPv4Unspecified(), 0), true
+	}
+	if prefix, err := netip.ParsePrefix(s); err == nil {
+		return prefix.Masked(), true
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), true
+}
+
+// parseNetToolsRoute parses the arguments of route after its name.
+func parseNetToolsRoute(args []string) (Route, string, bool) {
+	r, ok := parseNetToolsArgs(args)
+	if !ok {
+		return Route{}, "", false
+	}
+	verb := args[0]
+	if args[0] == "-A" {
+		verb = args[2]
+	}
+	return r, verb, true
+}
+
+// parseNetToolsArgs parses the arguments of route add or route del.
+func parseNetToolsArgs(args []string) (Route, bool) {
+	family := 4
+	if len(args) >= 2 && args[0] == "-A" {
+		if args[1] != "inet6" && args[1] != "inet" {
+			return Route{}, false
+		}
+		if args[1] == "inet6" {
+			family = 6
+		}
+		args = args[2:]
+	}
+	if len(args) < 2 || (args[0] != "add" && args[0] != "del") {
+		return Route{}, false
+	}
+	args = args[1:]
+	host := false
+	switch args[0] {
+	case "-net":
+		args = args[1:]
+	case "-host":
+		host = true
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return Route{}, false
+	}
+
+	r := Route{Style: RouteStyleNetTools}
+	dst, ok := parseDestination(args[0], family)
+	if !ok || (host && !dst.IsSingleIP()) {
+		return Route{}, false
+	}
+	r.Destination = dst
+	for i := 1; i < len(args); i++ {
+		key := args[i]
+		if i+1 >= len(args) {
+			// route takes the device as a last bare word, too
+			if r.Dev == "" && !strings.HasPrefix(key, "-") {
+				r.Dev = key
+				continue
+			}
+			return Route{}, false
+		}
+		value := args[i+1]
+		i++
+		var err error
+		switch key {
+		case "netmask":
+			mask := net.ParseIP(value).To4()
+			ones, bits := net.IPMask(mask).Size()
+			if mask == nil || bits == 0 || !dst.Addr().Is4() {
+				return Route{}, false
+			}
+			r.Destination = netip.PrefixFrom(dst.Addr(), ones).Masked()
+		case "gw":
+			r.Via, err = netip.ParseAddr(value)
+		case "metric":
+			r.Metric, err = strconv.Atoi(value)
+		case "dev":
+			r.Dev = value
+		default:
+			return Route{}, false
+		}
+		if err != nil {
+			return Route{}, false
+		}
+	}
+	return r, true
+}
+
+// args renders what follows "ip route add" for r.
+func (r Route) args() string {
+	var b strings.Builder
+	if r.Destination.Bits() == 0 {
+		b.WriteString("default")
+	} else if r.Destination.IsSingleIP() {
+		b.WriteString(r.Destination.Addr().String())
+	} else {
+		b.WriteString(r.Destination.String())
+	}
+	if r.Via.IsValid() {
+		b.WriteString(" via " + r.Via.String())
+	}
+	if r.Src.IsValid() {
+		b.WriteString(" src " + r.Src.String())
+	}
+	if r.Dev != "" {
+		b.WriteString(" dev " + quoteArg(r.Dev))
+	}
+	if r.Metric != 0 {
+		b.WriteString(" metric " + strconv.Itoa(r.Metric))
+	}
+	if r.Table != "" {
+		b.WriteString(" table " + quoteArg(r.Table))
+	}
+	if r.Proto != "" {
+		b.WriteString(" proto " + quoteArg(r.Proto))
+	}
+	if r.Scope != "" {
+		b.WriteString(" scope " + quoteArg(r.Scope))
+	}
+	if r.MTU != 0 {
+		b.WriteString(" mtu " + strconv.Itoa(r.MTU))
+	}
+	if r.OnLink {
+		b.WriteString(" onlink")
+	}
+	return b.String()
+}
+
+// ipFlag returns the family flag ip needs for r, which is only the case for
+// an IPv6 default route.
+func (r Route) ipFlag() string {
+	if r.Destination.Bits() == 0 && r.Destination.Addr().Is6() {
+		return "-6 "
+	}
+	return ""
+}
+
+// netToolsCan reports whether the route command of net-tools can express r.
+func (r Route) netToolsCan() bool {
+	return r.Table == "" && !r.OnLink && !r.Src.IsValid() && r.Proto == "" && r.Scope == "" && r.MTU == 0
+}
+
+// Command returns the hook that adds r, in its style. Routes in
+// RouteStyleOption, and routes net-tools cannot express, are written with ip.
+func (r Route) Command() string {
+	if r.Style == RouteStyleNetTools && r.netToolsCan() {
+		return r.netTools("add")
+	}
+	return "ip " + r.ipFlag() + "route add " + r.args()
+}
+
+// DelCommand returns the hook that removes r again.
+func (r Route) DelCommand() string {
+	if r.Style == RouteStyleNetTools && r.netToolsCan() {
+		return r.netTools("del")
+	}
+	return "ip " + r.ipFlag() + "route del " + r.args()
+}
+
+func (r Route) netTools(verb string) string {
+	var b strings.Builder
+	b.WriteString("route ")
+	addr := r.Destination.Addr()
+	if addr.Is6() {
+		b.WriteString("-A inet6 ")
+	}
+	b.WriteString(verb)
+	switch {
+	case r.Destination.Bits() == 0:
+		b.WriteString(" default")
+	case r.Destination.IsSingleIP():
+		b.WriteString(" -host " + addr.String())
+	case addr.Is4():
+		mask := net.CIDRMask(r.Destination.Bits(), 32)
+		b.WriteString(" -net " + addr.String() + " netmask " + net.IP(mask).String())
+	default:
+		// route -A inet6 takes the prefix length with the address
+		b.WriteString(" " + r.Destination.String())
+	}
+	if r.Via.IsValid() {
+		b.WriteString(" gw " + r.Via.String())
+	}
+	if r.Metric != 0 {
+		b.WriteString(" metric " + strconv.Itoa(r.Metric))
+	}
+	if r.Dev != "" {
+		b.WriteString(" dev " + quoteArg(r.Dev))
+	}
+	return b.String()
+}
+
+// Option returns r as a route option.
+func (r Route) Option() Option {
+	return Option{Key: routeOption, Value: r.args()}
+}
+
+// parseRouteOption parses the value of a route option.
+func parseRouteOption(value string) (Route, bool) {
+	r, ok := parseRouteArgs(strings.Fields(value), 0)
+	r.Style = RouteStyleOption
+	return r, ok
+}
+
+// Routes returns the routes iface adds when it comes up: those added by its
+// pre-up and post-up hooks, then those of its route options.
+func (iface *NetworkInterface) Routes() []Route {
+	iface.mu.RLock()
+	defer iface.mu.RUnlock()
+	return iface.routes()
+}
+
+// validateRoutes checks that every route option can be parsed. The caller must
+// hold at least the read lock.
+func (iface *NetworkInterface) validateRoutes() []error {
+	var errs []error
+	for _, opt := range iface.Options {
+		if _, ok := parseRouteOption(opt.Value); opt.Key == routeOption && !ok {
+			errs = append(errs, fmt.Errorf("[%s] %w: route %s", iface.Name, ErrInvalidIfaceData, opt.Value))
+		}
+	}
+	return errs
+}
+
+func (iface *NetworkInterface) routes() []Route {
+	var routes []Route
+	for _, hooks := range [][]string{iface.Hooks.PreUp, iface.Hooks.PostUp} {
+		for _, hook := range hooks {
+			if r, ok := ParseRoute(hook); ok {
+				routes = append(routes, r)
+			}
+		}
+	}
+	for _, opt := range iface.Options {
+		if opt.Key != routeOption {
+			continue
+		}
+		if r, ok := parseRouteOption(opt.Value); ok {
+			routes = append(routes, r)
+		}
+	}
+	return routes
+}
+
+// WithRoute adds r to the interface, as a post-up hook or as a route option
+// depending on its Style. Routes without a style become ip hooks.
+func (iface *NetworkInterface) WithRoute(r Route) *NetworkInterface {
+	iface.allocate()
+	defer iface.mu.Unlock()
+	if !r.Destination.IsValid() {
+		iface.errs = append(iface.errs, fmt.Errorf("%w: route without destination", ErrInvalidIfaceData))
+		return iface
+	}
+	iface.addRoute(r)
+	return iface
+}
+
+// addRoute adds r in its style. The caller must hold the write lock.
+func (iface *NetworkInterface) addRoute(r Route) {
+	if r.Style == RouteStyleOption {
+		iface.Options = append(iface.Options, r.Option())
+		return
+	}
+	iface.Hooks.PostUp = append(iface.Hooks.PostUp, r.Command())
+}
+
+// sameRoute reports whether a and b are the same route, whatever their style.
+func sameRoute(a, b Route) bool {
+	a.Style, b.Style = "", ""
+	return a == b
+}
+
+// RewriteRoutes rewrites every route of the interface in style: the hooks
+// and route options that add a route are replaced by ones in style. Hooks
+// that remove routes are left alone, and so is every route that cannot be
+// written in style exactly as it is. That covers pre-up hooks and hooks
+// ending in "|| true" when rewriting to route options, as options are applied
+// in another phase and cannot ignore failure, and routes whose arguments
+// route options cannot hold. Hooks rewritten as hooks keep their "|| true".
+func (iface *NetworkInterface) RewriteRoutes(style RouteStyle) *NetworkInterface {
+	iface.allocate()
+	defer iface.mu.Unlock()
+
+	rewrite := func(r Route) Route {
+		r.Style = style
+		return r
+	}
+	// asHook returns r written as a hook in style, if that parses back to r.
+	asHook := func(r Route, orTrue bool) (string, bool) {
+		hook := rewrite(r).Command()
+		if again, ok := ParseRoute(hook); !ok || !sameRoute(again, r) {
+			return "", false
+		}
+		if orTrue {
+			hook += " || true"
+		}
+		return hook, true
+	}
+
+	for _, phase := range []HookPhase{PhasePreUp, PhasePostUp} {
+		hooks := &iface.Hooks.PreUp
+		if phase == PhasePostUp {
+			hooks = &iface.Hooks.PostUp
+		}
+		kept := (*hooks)[:0:0]
+		for _, hook := range *hooks {
+			r, ok := ParseRoute(hook)
+			if !ok {
+				kept = append(kept, hook)
+				continue
+			}
+			_, orTrue := cutOrTrue(hook)
+			if style == RouteStyleOption {
+				opt := rewrite(r).Option()
+				if again, ok := parseRouteOption(opt.Value); phase == PhasePostUp && !orTrue && ok && sameRoute(again, r) {
+					iface.Options = append(iface.Options, opt)
+					continue
+				}
+				kept = append(kept, hook)
+				continue
+			}
+			if rewritten, ok := asHook(r, orTrue); ok {
+				hook = rewritten
+			}
+			kept = append(kept, hook)
+		}
+		*hooks = kept
+	}
+	if style == RouteStyleOption {
+		return iface
+	}
+	kept := iface.Options[:0:0]
+	for _, opt := range iface.Options {
+		if r, ok := parseRouteOption(opt.Value); opt.Key == routeOption && ok {
+			if hook, ok := asHook(r, false); ok {
+				iface.Hooks.PostUp = append(iface.Hooks.PostUp, hook)
+				continue
+			}
+		}
+		kept = append(kept, opt)
+	}
+	iface.Options = kept
+	return iface
+}
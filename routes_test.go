@@ -0,0 +1,238 @@
+package ifupdown
+
+import (
+	"errors"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestParseRoute(t *testing.T) {
+	for command, want := range map[string]Route{
+		"ip route add 10.1.0.0/16 via 10.0.0.253": {
+			Destination: netip.MustParsePrefix("10.1.0.0/16"),
+			Via:         netip.MustParseAddr("10.0.0.253"),
+			Style:       RouteStyleIP,
+		},
+		"/sbin/ip -4 ro add 192.0.2.7 dev $IFACE metric 5 table dmz onlink || true": {
+			Destination: netip.MustParsePrefix("192.0.2.7/32"),
+			Dev:         "$IFACE",
+			Metric:      5,
+			Table:       "dmz",
+			OnLink:      true,
+			Style:       RouteStyleIP,
+		},
+		"ip -6 route replace default via fe80::1 dev eth0": {
+			Destination: netip.MustParsePrefix("::/0"),
+			Via:         netip.MustParseAddr("fe80::1"),
+			Dev:         "eth0",
+			Style:       RouteStyleIP,
+		},
+		"ip route add default via 2001:db8::1 src 2001:db8::10": {
+			Destination: netip.MustParsePrefix("::/0"),
+			Via:         netip.MustParseAddr("2001:db8::1"),
+			Src:         netip.MustParseAddr("2001:db8::10"),
+			Style:       RouteStyleIP,
+		},
+		"route add -net 172.16.0.0 netmask 255.240.0.0 gw 10.0.0.1 metric 2 dev eth0": {
+			Destination: netip.MustParsePrefix("172.16.0.0/12"),
+			Via:         netip.MustParseAddr("10.0.0.1"),
+			Metric:      2,
+			Dev:         "eth0",
+			Style:       RouteStyleNetTools,
+		},
+		"/sbin/route add -host 198.51.100.1 gw 10.0.0.1 eth0": {
+			Destination: netip.MustParsePrefix("198.51.100.1/32"),
+			Via:         netip.MustParseAddr("10.0.0.1"),
+			Dev:         "eth0",
+			Style:       RouteStyleNetTools,
+		},
+		"route -A inet6 add 2001:db8:5::/48 gw 2001:db8::1": {
+			Destination: netip.MustParsePrefix("2001:db8:5::/48"),
+			Via:         netip.MustParseAddr("2001:db8::1"),
+			Style:       RouteStyleNetTools,
+		},
+		"ip route add 10.1.0.0/16 via 10.0.0.253 proto static scope global mtu 1400": {
+			Destination: netip.MustParsePrefix("10.1.0.0/16"),
+			Via:         netip.MustParseAddr("10.0.0.253"),
+			Proto:       "static",
+			Scope:       "global",
+			MTU:         1400,
+			Style:       RouteStyleIP,
+		},
+		`ip route add 10.2.0.0/16 dev "$IFACE" table 'isp two'`: {},
+		`ip route add 10.3.0.0/16 dev 'wan 0' table isp\ two`: {
+			Destination: netip.MustParsePrefix("10.3.0.0/16"),
+			Dev:         "wan 0",
+			Table:       "isp two",
+			Style:       RouteStyleIP,
+		},
+	} {
+		if want == (Route{}) {
+			// $ inside quotes is beyond commandArgs
+			if r, ok := ParseRoute(command); ok {
+				t.Errorf("ParseRoute(%q) = %+v, want no route", command, r)
+			}
+			continue
+		}
+		got, ok := ParseRoute(command)
+		if !ok || got != want {
+			t.Errorf("ParseRoute(%q) = %+v, %v, want %+v", command, got, ok, want)
+		}
+	}
+
+	for _, command := range []string{
+		"ip route del 10.1.0.0/16",
+		"ip rule add from 10.0.0.2 table 10",
+		"ip route add 10.1.0.0/16 via 10.0.0.253 mtu lock 1400",
+		"ip route add 10.1.0.0/16 via '10.0.0.253",
+		"ip route add 10.1.0.0/16 via $(cat /etc/gw)",
+		"ip route add 10.1.0.0/16 via 10.0.0.253 && echo done",
+		"route add -host 10.0.0.0/8 gw 10.0.0.1",
+		"echo route add default gw 10.0.0.1",
+	} {
+		if r, ok := ParseRoute(command); ok {
+			t.Errorf("ParseRoute(%q) = %+v, want no route", command, r)
+		}
+	}
+}
+
+func TestRoute_Command(t *testing.T) {
+	for command, want := range map[string]string{
+		"ip route add 10.1.0.0/16 via 10.0.0.253 dev $IFACE":          "ip route add 10.1.0.0/16 via 10.0.0.253 dev $IFACE",
+		"ip -6 route add default via fe80::1 dev eth0 onlink":         "ip -6 route add default via fe80::1 dev eth0 onlink",
+		"ip r add 192.0.2.7/32 table 10 metric 3":                     "ip route add 192.0.2.7 metric 3 table 10",
+		"route add -net 172.16.0.0/12 gw 10.0.0.1":                    "route add -net 172.16.0.0 netmask 255.240.0.0 gw 10.0.0.1",
+		"route add default gw 10.0.0.1 dev eth0":                      "route add default gw 10.0.0.1 dev eth0",
+		"route -A inet6 add 2001:db8:5::/48 gw 2001:db8::1 metric 10": "route -A inet6 add 2001:db8:5::/48 gw 2001:db8::1 metric 10",
+		"ip route add 10.1.0.0/16 dev 'wan 0' proto boot scope link":  "ip route add 10.1.0.0/16 dev 'wan 0' proto boot scope link",
+	} {
+		r, ok := ParseRoute(command)
+		if !ok {
+			t.Errorf("ParseRoute(%q) failed", command)
+			continue
+		}
+		if got := r.Command(); got != want {
+			t.Errorf("Command() of %q = %q, want %q", command, got, want)
+		}
+		if again, _ := ParseRoute(r.Command()); again != r {
+			t.Errorf("%q does not parse back: %+v, want %+v", r.Command(), again, r)
+		}
+	}
+}
+
+const routesFile = `iface eth0 inet static
+	address 10.0.0.2/24
+	gateway 10.0.0.1
+	pre-up echo starting
+	up ip route add 10.1.0.0/16 via 10.0.0.253
+	post-up route add -net 172.16.0.0 netmask 255.240.0.0 gw 10.0.0.254
+	post-up logger up
+	route 192.168.50.0/24 via 10.0.0.9 metric 20
+	down ip route del 10.1.0.0/16 via 10.0.0.253
+`
+
+func TestNetworkInterface_Routes(t *testing.T) {
+	eth0 := parseInterfaces(t, routesFile)["eth0"]
+	if err := eth0.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	routes := eth0.Routes()
+	var got []string
+	for _, r := range routes {
+		got = append(got, string(r.Style)+" "+r.Destination.String()+" via "+r.Via.String())
+	}
+	want := []string{
+		"ip 10.1.0.0/16 via 10.0.0.253",
+		"route 172.16.0.0/12 via 10.0.0.254",
+		"option 192.168.50.0/24 via 10.0.0.9",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Routes() = %q, want %q", got, want)
+	}
+	if routes[2].Metric != 20 {
+		t.Errorf("metric of the route option = %d", routes[2].Metric)
+	}
+
+	asOptions := eth0.Clone().RewriteRoutes(RouteStyleOption)
+	if !slices.Equal(asOptions.Hooks.PostUp, []string{"logger up"}) {
+		t.Errorf("post-up after RewriteRoutes(option) = %q", asOptions.Hooks.PostUp)
+	}
+	var values []string
+	for _, opt := range asOptions.Options {
+		values = append(values, opt.Key+" "+opt.Value)
+	}
+	if !slices.Equal(values, []string{
+		"route 192.168.50.0/24 via 10.0.0.9 metric 20",
+		"route 10.1.0.0/16 via 10.0.0.253",
+		"route 172.16.0.0/12 via 10.0.0.254",
+	}) {
+		t.Errorf("options after RewriteRoutes(option) = %q", values)
+	}
+	if len(asOptions.Routes()) != 3 || !slices.Equal(asOptions.Hooks.PreDown, eth0.Hooks.PreDown) {
+		t.Errorf("RewriteRoutes(option) lost routes or touched down hooks: %+v", asOptions.Hooks)
+	}
+
+	asHooks := eth0.Clone().RewriteRoutes(RouteStyleIP)
+	if !slices.Equal(asHooks.Hooks.PostUp, []string{
+		"ip route add 10.1.0.0/16 via 10.0.0.253",
+		"ip route add 172.16.0.0/12 via 10.0.0.254",
+		"logger up",
+		"ip route add 192.168.50.0/24 via 10.0.0.9 metric 20",
+	}) {
+		t.Errorf("post-up after RewriteRoutes(ip) = %q", asHooks.Hooks.PostUp)
+	}
+	if len(asHooks.Options) != 0 {
+		t.Errorf("options after RewriteRoutes(ip) = %v", asHooks.Options)
+	}
+}
+
+func TestNetworkInterface_WithRoute(t *testing.T) {
+	iface := NewNetworkInterface("eth0").WithDHCP().WithVersion(AddressVersion4).
+		WithRoute(Route{Destination: netip.MustParsePrefix("10.9.0.0/16"), Via: netip.MustParseAddr("10.0.0.1")}).
+		WithRoute(Route{Destination: netip.MustParsePrefix("10.8.0.0/16"), Dev: "eth0", Style: RouteStyleOption})
+	want := "iface eth0 inet dhcp\n" +
+		"\troute 10.8.0.0/16 dev eth0\n" +
+		"\tpost-up ip route add 10.9.0.0/16 via 10.0.0.1\n"
+	if got := iface.String(); got != "auto eth0\n"+want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+
+	bad := parseInterfaces(t, "iface eth0 inet dhcp\n\troute somewhere\n")["eth0"]
+	if err := bad.Validate(); !errors.Is(err, ErrInvalidIfaceData) {
+		t.Errorf("Validate() = %v, want %v", err, ErrInvalidIfaceData)
+	}
+}
+
+func TestNetworkInterface_RewriteRoutes_Exact(t *testing.T) {
+	eth0 := parseInterfaces(t, `iface eth0 inet manual
+	pre-up ip route add 10.1.0.0/16 dev eth0
+	post-up ip route add 10.2.0.0/16 dev eth0 || true
+	post-up route add -net 10.3.0.0/16 dev 'wan 0'
+	post-up ip route add 10.4.0.0/16 dev eth0 proto static mtu 1400
+`)["eth0"]
+
+	asOptions := eth0.Clone().RewriteRoutes(RouteStyleOption)
+	if want := []string{"ip route add 10.1.0.0/16 dev eth0"}; !slices.Equal(asOptions.Hooks.PreUp, want) {
+		t.Errorf("pre-up after RewriteRoutes(option) = %q, want %q", asOptions.Hooks.PreUp, want)
+	}
+	if want := []string{
+		"ip route add 10.2.0.0/16 dev eth0 || true",
+		"route add -net 10.3.0.0/16 dev 'wan 0'",
+	}; !slices.Equal(asOptions.Hooks.PostUp, want) {
+		t.Errorf("post-up after RewriteRoutes(option) = %q, want %q", asOptions.Hooks.PostUp, want)
+	}
+	if len(asOptions.Options) != 1 || asOptions.Options[0].Value != "10.4.0.0/16 dev eth0 proto static mtu 1400" {
+		t.Errorf("options after RewriteRoutes(option) = %v", asOptions.Options)
+	}
+
+	asNetTools := eth0.Clone().RewriteRoutes(RouteStyleNetTools)
+	if want := []string{
+		"route add -net 10.2.0.0 netmask 255.255.0.0 dev eth0 || true",
+		"route add -net 10.3.0.0 netmask 255.255.0.0 dev 'wan 0'",
+		"ip route add 10.4.0.0/16 dev eth0 proto static mtu 1400",
+	}; !slices.Equal(asNetTools.Hooks.PostUp, want) {
+		t.Errorf("post-up after RewriteRoutes(route) = %q, want %q", asNetTools.Hooks.PostUp, want)
+	}
+}
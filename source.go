@@ -0,0 +1,130 @@
+package ifupdown
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxSourceDepth limits how deep source and source-directory lines are
+// followed, mostly to stop files that source themselves.
+const maxSourceDepth = 16
+
+// runPartsName matches the file names run-parts(8) and ifupdown's
+// source-directory consider. Anything else in the directory is skipped.
+var runPartsName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// ParseFile reads the interfaces file at name, following any source and
+// source-directory lines, and parses the result.
+func ParseFile(name string) (Interfaces, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFS(os.DirFS("/"), abs)
+}
+
+// ParseFS is like ParseFile but reads from fsys. Absolute paths, both in name
+// and in source lines, are taken relative to the root of fsys.
+func ParseFS(fsys fs.FS, name string) (Interfaces, error) {
+	data, err := ExpandSources(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	mp := NewMultiParser()
+	_, _ = mp.Write(data)
+	return mp.Parse()
+}
+
+// ExpandSources reads name from fsys and returns its contents with every
+// source and source-directory line replaced by the files it refers to.
+// Relative paths in those lines are resolved against the directory of the
+// file that contains them.
+func ExpandSources(fsys fs.FS, name string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := expandSources(fsys, fsPath(name), buf, 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func expandSources(fsys fs.FS, name string, buf *bytes.Buffer, depth int) error {
+	if depth > maxSourceDepth {
+		return fmt.Errorf("%w: %s", ErrSourceDepth, name)
+	}
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	lines := newLineReader(bytes.NewReader(data))
+	for {
+		line, ok := lines.next()
+		if !ok {
+			break
+		}
+		fields := tokens(line)
+		if len(fields) < 2 || (fields[0] != "source" && fields[0] != "source-directory") {
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+			continue
+		}
+
+		files, err := sourced(fsys, name, fields)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err = expandSources(fsys, file, buf, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return lines.err()
+}
+
+// sourced lists the files that the source or source-directory line fields,
+// found in the file name, pulls in.
+func sourced(fsys fs.FS, name string, fields []string) ([]string, error) {
+	target := fields[1]
+	if !path.IsAbs(target) {
+		target = path.Join(path.Dir(name), target)
+	}
+	target = fsPath(target)
+
+	if fields[0] == "source-directory" {
+		return runParts(fsys, target)
+	}
+	return fs.Glob(fsys, target)
+}
+
+// runParts lists the files in dir that source-directory would include.
+func runParts(fsys fs.FS, dir string) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !runPartsName.MatchString(entry.Name()) {
+			continue
+		}
+		files = append(files, path.Join(dir, entry.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// fsPath turns a slash separated path into a path valid for fs.FS.
+func fsPath(name string) string {
+	name = strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
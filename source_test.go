@@ -0,0 +1,51 @@
+package ifupdown
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestExpandSources(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/network/interfaces":                 {Data: []byte("auto lo\niface lo inet loopback\n\nsource-directory interfaces.d\nsource /etc/network/extra/*\n")},
+		"etc/network/interfaces.d/eth0":          {Data: []byte("auto eth0\niface eth0 inet dhcp\n")},
+		"etc/network/interfaces.d/eth0.dpkg-old": {Data: []byte("auto eth9\niface eth9 inet dhcp\n")},
+		"etc/network/extra/eth1.cfg":             {Data: []byte("auto eth1\niface eth1 inet dhcp\n")},
+	}
+
+	dat, err := ExpandSources(fsys, "/etc/network/interfaces")
+	if err != nil {
+		t.Fatalf("ExpandSources() = %v", err)
+	}
+	got := string(dat)
+	for _, want := range []string{"iface lo", "iface eth0", "iface eth1"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expanded data missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "eth9") {
+		t.Errorf("source-directory included a file run-parts would skip:\n%s", got)
+	}
+	if strings.Contains(got, "source") {
+		t.Errorf("source lines left in expanded data:\n%s", got)
+	}
+
+	ifaces, err := ParseFS(fsys, "/etc/network/interfaces")
+	if err != nil {
+		t.Fatalf("ParseFS() = %v", err)
+	}
+	if len(ifaces) != 3 {
+		t.Errorf("got %d interfaces, want 3: %v", len(ifaces), ifaces.names())
+	}
+}
+
+func TestExpandSources_Loop(t *testing.T) {
+	fsys := fstest.MapFS{
+		"interfaces": {Data: []byte("source interfaces\n")},
+	}
+	if _, err := ExpandSources(fsys, "interfaces"); !errors.Is(err, ErrSourceDepth) {
+		t.Errorf("ExpandSources() = %v, want %v", err, ErrSourceDepth)
+	}
+}
@@ -0,0 +1,8 @@
+package state
+
+import "errors"
+
+var (
+	ErrInvalidEntry = errors.New("invalid state entry")
+	ErrClosed       = errors.New("state transaction already closed")
+)
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package state
+
+import (
+	"errors"
+	"os"
+)
+
+func lockFile(*os.File) error {
+	return errors.ErrUnsupported
+}
+
+func unlockFile(*os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package state
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// lockFile takes a POSIX write lock on the whole of f, as ifupdown does, so
+// that the two exclude each other. flock(2) locks would not.
+func lockFile(f *os.File) error {
+	return fcntlLock(f, syscall.F_WRLCK)
+}
+
+func unlockFile(f *os.File) error {
+	return fcntlLock(f, syscall.F_UNLCK)
+}
+
+func fcntlLock(f *os.File, typ int16) error {
+	lk := &syscall.Flock_t{Type: typ, Whence: io.SeekStart}
+	for {
+		err := syscall.FcntlFlock(f.Fd(), syscall.F_SETLKW, lk)
+		if !errors.Is(err, syscall.EINTR) {
+			return err
+		}
+	}
+}
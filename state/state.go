@@ -0,0 +1,295 @@
+// Package state reads and updates the state ifupdown keeps of the interfaces
+// it has brought up, normally in /run/network.
+//
+// The directory holds an ifstate file listing every configured interface as
+// a physical=logical line, a file per interface called ifstate.<physical>
+// holding the same line (or nothing once the interface is down), and a
+// .ifstate.lock file that serialises updates.
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"git.tcp.direct/kayos/ifupdown"
+)
+
+// DefaultDir is where ifupdown keeps its state on Debian based systems.
+const DefaultDir = "/run/network"
+
+const (
+	stateFile = "ifstate"
+	lockName  = ".ifstate.lock"
+)
+
+// The lock file holds a POSIX record lock, which belongs to the process, so
+// transactions within the process are serialised by a mutex per lock file.
+var (
+	lockMu sync.Mutex
+	locks  = make(map[string]*sync.Mutex)
+)
+
+func processLock(name string) *sync.Mutex {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+	mu, ok := locks[name]
+	if !ok {
+		mu = &sync.Mutex{}
+		locks[name] = mu
+	}
+	return mu
+}
+
+// Entry records that the physical interface is up with the configuration
+// of the logical interface. The two differ when a mapping picked the
+// configuration, as in eth0=eth0-home.
+type Entry struct {
+	Physical string `json:"physical"`
+	Logical  string `json:"logical"`
+}
+
+func (e Entry) String() string {
+	return e.Physical + "=" + e.Logical
+}
+
+// ParseEntry parses a physical=logical line. A line without "=" names an
+// interface that is configured under its own name.
+func ParseEntry(line string) (Entry, error) {
+	line = strings.TrimSpace(line)
+	physical, logical, found := strings.Cut(line, "=")
+	if !found {
+		logical = physical
+	}
+	if physical == "" || logical == "" || strings.ContainsAny(line, " \t/") {
+		return Entry{}, fmt.Errorf("%w: %q", ErrInvalidEntry, line)
+	}
+	return Entry{Physical: physical, Logical: logical}, nil
+}
+
+// Entries is the state of every interface ifupdown considers up, in the order
+// they were brought up.
+type Entries []Entry
+
+// Lookup returns the entry for the physical interface.
+func (es Entries) Lookup(physical string) (Entry, bool) {
+	for _, e := range es {
+		if e.Physical == physical {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Interfaces maps each physical interface in es to the stanza of its logical
+// interface in ifaces. Entries whose logical interface has no stanza are left
+// out.
+func (es Entries) Interfaces(ifaces ifupdown.Interfaces) ifupdown.Interfaces {
+	up := make(ifupdown.Interfaces, len(es))
+	for _, e := range es {
+		if iface, ok := ifaces[e.Logical]; ok {
+			up[e.Physical] = iface
+		}
+	}
+	return up
+}
+
+func (es Entries) set(e Entry) Entries {
+	for i := range es {
+		if es[i].Physical == e.Physical {
+			es[i] = e
+			return es
+		}
+	}
+	return append(es, e)
+}
+
+func (es Entries) remove(physical string) Entries {
+	for i := range es {
+		if es[i].Physical == physical {
+			return append(es[:i], es[i+1:]...)
+		}
+	}
+	return es
+}
+
+func parseEntries(data []byte) (Entries, error) {
+	var es Entries
+	xerox := bufio.NewScanner(bytes.NewReader(data))
+	for xerox.Scan() {
+		if strings.TrimSpace(xerox.Text()) == "" {
+			continue
+		}
+		e, err := ParseEntry(xerox.Text())
+		if err != nil {
+			return nil, err
+		}
+		es = es.set(e)
+	}
+	return es, xerox.Err()
+}
+
+// Dir is an ifupdown state directory.
+type Dir string
+
+// Read returns the current state without taking the lock. The per interface
+// files take precedence over the ifstate file, as ifupdown writes them first.
+func (d Dir) Read() (Entries, error) {
+	data, err := os.ReadFile(filepath.Join(string(d), stateFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	es, err := parseEntries(data)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(string(d), stateFile+".*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		physical := strings.TrimPrefix(filepath.Base(file), stateFile+".")
+		if strings.HasSuffix(physical, ".lock") {
+			continue
+		}
+		if data, err = os.ReadFile(file); err != nil {
+			return nil, err
+		}
+		ifes, err := parseEntries(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if len(ifes) == 0 {
+			es = es.remove(physical)
+			continue
+		}
+		for _, e := range ifes {
+			es = es.set(e)
+		}
+	}
+	return es, nil
+}
+
+// Lock takes the ifupdown state lock, blocking until it is available, and
+// returns a transaction holding the current state.
+func (d Dir) Lock() (*Tx, error) {
+	if err := os.MkdirAll(string(d), 0o755); err != nil {
+		return nil, err
+	}
+	name, err := filepath.Abs(filepath.Join(string(d), lockName))
+	if err != nil {
+		return nil, err
+	}
+	mu := processLock(name)
+	mu.Lock()
+
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		mu.Unlock()
+		return nil, err
+	}
+	if err = lockFile(f); err != nil {
+		_ = f.Close()
+		mu.Unlock()
+		return nil, err
+	}
+	es, err := d.Read()
+	if err != nil {
+		_ = unlockFile(f)
+		_ = f.Close()
+		mu.Unlock()
+		return nil, err
+	}
+	return &Tx{dir: d, lock: f, mu: mu, entries: es, touched: make(map[string]bool)}, nil
+}
+
+// Tx is a locked view of the state that can be modified and written back.
+type Tx struct {
+	dir     Dir
+	lock    *os.File
+	mu      *sync.Mutex
+	entries Entries
+	// touched records the physical interfaces whose files need rewriting.
+	touched map[string]bool
+}
+
+// Entries returns the state as seen by the transaction.
+func (tx *Tx) Entries() Entries {
+	return append(Entries(nil), tx.entries...)
+}
+
+// Set records physical as up with the configuration of logical.
+func (tx *Tx) Set(physical, logical string) error {
+	e, err := ParseEntry(physical + "=" + logical)
+	if err != nil {
+		return err
+	}
+	tx.entries = tx.entries.set(e)
+	tx.touched[physical] = true
+	return nil
+}
+
+// Remove records physical as down.
+func (tx *Tx) Remove(physical string) {
+	tx.entries = tx.entries.remove(physical)
+	tx.touched[physical] = true
+}
+
+// Commit writes the state back and releases the lock.
+func (tx *Tx) Commit() error {
+	if tx.lock == nil {
+		return ErrClosed
+	}
+	err := tx.write()
+	return errors.Join(err, tx.Close())
+}
+
+func (tx *Tx) write() error {
+	dir := string(tx.dir)
+	for physical := range tx.touched {
+		var data []byte
+		if e, ok := tx.entries.Lookup(physical); ok {
+			data = []byte(e.String() + "\n")
+		}
+		if err := writeFile(dir, stateFile+"."+physical, data); err != nil {
+			return err
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	for _, e := range tx.entries {
+		buf.WriteString(e.String())
+		buf.WriteByte('\n')
+	}
+	return writeFile(dir, stateFile, buf.Bytes())
+}
+
+// writeFile replaces name in dir with data through a temporary file, so that
+// readers never see a partly written file. The temporary name starts with a
+// dot, which Read does not look at. It is fixed, as writes happen under the
+// lock.
+func writeFile(dir, name string, data []byte) error {
+	tmp := filepath.Join(dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, name))
+}
+
+// Close releases the lock without writing anything.
+func (tx *Tx) Close() error {
+	if tx.lock == nil {
+		return nil
+	}
+	err := errors.Join(unlockFile(tx.lock), tx.lock.Close())
+	tx.lock = nil
+	tx.mu.Unlock()
+	return err
+}
@@ -0,0 +1,138 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"git.tcp.direct/kayos/ifupdown"
+)
+
+func TestParseEntry(t *testing.T) {
+	for line, want := range map[string]Entry{
+		"eth0=eth0-home": {Physical: "eth0", Logical: "eth0-home"},
+		"lo=lo\n":        {Physical: "lo", Logical: "lo"},
+		"eth1":           {Physical: "eth1", Logical: "eth1"},
+	} {
+		got, err := ParseEntry(line)
+		if err != nil || got != want {
+			t.Errorf("ParseEntry(%q) = %v, %v, want %v", line, got, err, want)
+		}
+	}
+	for _, line := range []string{"=eth0", "eth0=", "eth0 = eth1", "../eth0=eth0"} {
+		if _, err := ParseEntry(line); !errors.Is(err, ErrInvalidEntry) {
+			t.Errorf("ParseEntry(%q) = %v, want %v", line, err, ErrInvalidEntry)
+		}
+	}
+}
+
+func TestDir_Read(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("ifstate", "lo=lo\neth0=eth0\neth1=eth1\n")
+	write("ifstate.eth0", "eth0=eth0-home\n")
+	write("ifstate.eth1", "")
+	write("ifstate.eth2", "eth2=eth2\n")
+
+	es, err := Dir(dir).Read()
+	if err != nil {
+		t.Fatalf("Read() = %v", err)
+	}
+	want := Entries{{"lo", "lo"}, {"eth0", "eth0-home"}, {"eth2", "eth2"}}
+	if !slices.Equal(es, want) {
+		t.Errorf("Read() = %v, want %v", es, want)
+	}
+
+	ifaces := ifupdown.Interfaces{
+		"lo":        ifupdown.NewNetworkInterface("lo").WithLoopback(),
+		"eth0-home": ifupdown.NewNetworkInterface("eth0-home").WithDHCP(),
+	}
+	up := es.Interfaces(ifaces)
+	if len(up) != 2 || up["eth0"] != ifaces["eth0-home"] || up["lo"] != ifaces["lo"] {
+		t.Errorf("Interfaces() = %v", up)
+	}
+}
+
+func TestDir_Read_Missing(t *testing.T) {
+	es, err := Dir(filepath.Join(t.TempDir(), "network")).Read()
+	if err != nil || len(es) != 0 {
+		t.Errorf("Read() of a missing directory = %v, %v", es, err)
+	}
+}
+
+func TestTx(t *testing.T) {
+	dir := Dir(t.TempDir())
+
+	tx, err := dir.Lock()
+	if err != nil {
+		t.Fatalf("Lock() = %v", err)
+	}
+	_ = tx.Set("lo", "lo")
+	_ = tx.Set("eth0", "eth0")
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("Commit() = %v", err)
+	}
+	if err = tx.Commit(); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Commit() = %v, want %v", err, ErrClosed)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(string(dir), "ifstate"))
+	if string(data) != "lo=lo\neth0=eth0\n" {
+		t.Errorf("ifstate = %q", data)
+	}
+	data, _ = os.ReadFile(filepath.Join(string(dir), "ifstate.eth0"))
+	if string(data) != "eth0=eth0\n" {
+		t.Errorf("ifstate.eth0 = %q", data)
+	}
+
+	tx, _ = dir.Lock()
+	tx.Remove("eth0")
+	_ = tx.Commit()
+
+	data, _ = os.ReadFile(filepath.Join(string(dir), "ifstate.eth0"))
+	if len(data) != 0 {
+		t.Errorf("ifstate.eth0 after Remove() = %q", data)
+	}
+	if es, _ := dir.Read(); !slices.Equal(es, Entries{{"lo", "lo"}}) {
+		t.Errorf("Read() after Remove() = %v", es)
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(string(dir), ".*.tmp")); len(tmp) != 0 {
+		t.Errorf("temporary files left behind: %v", tmp)
+	}
+}
+
+func TestTx_Concurrent(t *testing.T) {
+	dir := Dir(t.TempDir())
+	wg := &sync.WaitGroup{}
+	for _, name := range []string{"eth0", "eth1", "eth2", "eth3", "eth4", "eth5", "eth6", "eth7"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			tx, err := dir.Lock()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = tx.Set(name, name)
+			if err = tx.Commit(); err != nil {
+				t.Error(err)
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	es, err := dir.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 8 {
+		t.Errorf("lost updates, state = %v", es)
+	}
+}
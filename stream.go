@@ -0,0 +1,255 @@
+package ifupdown
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Decoder reads interface stanzas from an input stream one at a time,
+// without holding the whole input in memory.
+type Decoder struct {
+	lines *lineReader
+	// next holds a line that ended the previous stanza and starts the next one.
+	next    string
+	hasNext bool
+	// classes maps interface names to the classes auto and allow-* lines
+	// put them in.
+	classes map[string][]string
+	// mappings collects the mapping stanzas seen so far.
+	mappings Mappings
+	// failed is set once a read error has been returned.
+	failed bool
+}
+
+// NewDecoder returns a Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{lines: newLineReader(r), classes: make(map[string][]string)}
+}
+
+// stanzaStart reports whether the first field of a line starts a new
+// top-level stanza in an interfaces file.
+func stanzaStart(keyword string) bool {
+	switch {
+	case keyword == "auto", keyword == "iface", strings.HasPrefix(keyword, "allow-"):
+		return true
+	case keyword == "mapping", keyword == "source", keyword == "source-directory",
+		keyword == "no-auto-down", keyword == "no-scripts", keyword == "rename":
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *Decoder) line() (string, bool) {
+	if d.hasNext {
+		d.hasNext = false
+		return d.next, true
+	}
+	return d.lines.next()
+}
+
+func (d *Decoder) unread(line string) {
+	d.next = line
+	d.hasNext = true
+}
+
+// Decode returns the next interface stanza in the input. It returns io.EOF
+// once the input is exhausted. An error in one stanza does not stop the
+// Decoder; the next call continues with the following stanza.
+func (d *Decoder) Decode() (*NetworkInterface, error) {
+	var (
+		iface *NetworkInterface
+		err   error
+	)
+
+scan:
+	for {
+		line, ok := d.line()
+		if !ok {
+			break
+		}
+		fields := tokens(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if stanzaStart(fields[0]) {
+			class, isClass := allowClass(fields[0])
+			switch {
+			case iface != nil:
+				// any top level line ends the stanza
+				d.unread(line)
+				break scan
+			case fields[0] == "mapping":
+				m, err := d.decodeMapping(fields[1:])
+				if err != nil {
+					return nil, err
+				}
+				d.mappings = append(d.mappings, m)
+				continue
+			case isClass:
+				for _, name := range fields[1:] {
+					d.classes[name] = append(d.classes[name], class)
+				}
+				continue
+			case fields[0] != "iface" || len(fields) < 2:
+				// not an interface stanza, skip it along with its options
+				d.skipStanza()
+				continue
+			default:
+				iface = NewNetworkInterface(fields[1])
+				iface.touch()
+				iface.Auto = false
+				for _, class := range d.classes[iface.Name] {
+					iface.allow(class)
+				}
+			}
+		}
+
+		if iface == nil {
+			// option lines outside of any stanza
+			continue
+		}
+		if err == nil {
+			if err = iface.parseLine(line); err != nil {
+				err = fmt.Errorf("[%s] %w", iface.Name, err)
+			}
+		}
+	}
+
+	if err == nil && !d.failed {
+		err = d.lines.err()
+		d.failed = err != nil
+	}
+	switch {
+	case err != nil:
+		return nil, err
+	case iface == nil:
+		return nil, io.EOF
+	default:
+		return iface, nil
+	}
+}
+
+// Classes returns the classes the auto and allow-* lines read so far put the
+// interface called name in. Decode applies them to the stanzas that follow
+// the lines; a stanza that came before a line naming it has to be updated
+// by the caller, as MultiParser does.
+func (d *Decoder) Classes(name string) []string {
+	return slices.Clone(d.classes[name])
+}
+
+// Mappings returns the mapping stanzas decoded so far. Decode skips over
+// them, as they are not interfaces.
+func (d *Decoder) Mappings() Mappings {
+	return slices.Clone(d.mappings)
+}
+
+// skipStanza discards the options of a stanza the Decoder does not handle.
+func (d *Decoder) skipStanza() {
+	for {
+		line, ok := d.line()
+		if !ok {
+			return
+		}
+		fields := tokens(line)
+		if len(fields) > 0 && stanzaStart(fields[0]) {
+			d.unread(line)
+			return
+		}
+	}
+}
+
+// Encoder writes interface stanzas to an output stream. Its output is
+// buffered; call Flush when done.
+type Encoder struct {
+	w   *bufio.Writer
+	err error
+	// stanzas leaves out the auto and allow-* lines, for when they are
+	// written grouped by class instead.
+	stanzas bool
+	// unchecked writes stanzas without validating them first.
+	unchecked bool
+	redact    bool
+}
+
+// NewEncoder returns an Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w)}
+}
+
+// Encode validates iface and writes it to the stream, followed by a blank line.
+func (e *Encoder) Encode(iface *NetworkInterface) error {
+	if e.err != nil {
+		return e.err
+	}
+	w := func(s string) {
+		if e.err == nil {
+			_, e.err = e.w.WriteString(s)
+		}
+	}
+	unchecked := e.unchecked
+	if e.redact {
+		if !unchecked {
+			if err := iface.Validate(); err != nil {
+				return err
+			}
+		}
+		// the copy may not validate without its secrets
+		iface, unchecked = iface.Redacted(), true
+	}
+
+	iface.mu.Lock()
+	var err error
+	if !unchecked {
+		err = iface.validate()
+	}
+	if err == nil {
+		if !e.stanzas {
+			iface.writeClasses(w)
+		}
+		err = iface.writeStanza(w)
+	}
+	iface.mu.Unlock()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if e.err == nil {
+		e.err = e.w.WriteByte('\n')
+	}
+	return e.err
+}
+
+// SetRedact makes the encoder write the secrets of interfaces, such as
+// wpa-psk, as Redacted. Interfaces are still validated with their secrets.
+func (e *Encoder) SetRedact(redact bool) {
+	e.redact = redact
+}
+
+// EncodeMapping validates m and writes it to the stream, followed by a blank
+// line.
+func (e *Encoder) EncodeMapping(m *Mapping) error {
+	if e.err != nil {
+		return e.err
+	}
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	if _, e.err = e.w.WriteString(m.String()); e.err == nil {
+		e.err = e.w.WriteByte('\n')
+	}
+	return e.err
+}
+
+// Flush writes any buffered stanzas to the underlying writer. It has to be
+// called once the last stanza has been encoded.
+func (e *Encoder) Flush() error {
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.err
+}
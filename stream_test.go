@@ -0,0 +1,169 @@
+package ifupdown
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecoder_Decode(t *testing.T) {
+	data := `# comment
+auto lo
+iface lo inet loopback
+
+mapping eth3
+	script /usr/local/sbin/map-scheme
+	map HOME eth3-home
+
+auto eth0.1
+iface eth0.1 inet static
+	address 10.0.1.1
+	netmask 255.255.255.0
+
+auto eth0.10
+iface eth0.10 inet static
+	address 10.0.10.1/24
+
+iface eth1 inet bogus
+
+source /etc/network/interfaces.d/*
+allow-hotplug eth2
+iface eth2 inet dhcp
+`
+	dec := NewDecoder(strings.NewReader(data))
+
+	var (
+		names []string
+		errs  []error
+	)
+	for {
+		iface, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err = iface.Validate(); err != nil {
+			t.Errorf("[%s] Validate() = %v", iface.Name, err)
+		}
+		names = append(names, iface.Name)
+	}
+
+	if got := strings.Join(names, " "); got != "lo eth0.1 eth0.10 eth2" {
+		t.Errorf("decoded %q, want %q", got, "lo eth0.1 eth0.10 eth2")
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrInvalidIfaceData) {
+		t.Errorf("errors = %v, want one %v", errs, ErrInvalidIfaceData)
+	}
+}
+
+func TestEncoder_Encode(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	ifaces := testInterfaces("10.0.0.5")
+	want := &strings.Builder{}
+	for _, name := range ifaces.names() {
+		if err := enc.Encode(ifaces[name]); err != nil {
+			t.Fatalf("Encode() = %v", err)
+		}
+		want.WriteString(ifaces[name].String() + "\n")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Encode() flushed %d bytes before Flush()", buf.Len())
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	if buf.String() != want.String() {
+		t.Errorf("Encode() wrote:\n%s\nwant:\n%s", buf.String(), want.String())
+	}
+
+	if err := enc.Encode(NewNetworkInterface("eth1")); err == nil {
+		t.Error("Encode() accepted an invalid interface")
+	}
+}
+
+// vlanFile generates an interfaces file with n VLAN interfaces.
+func vlanFile(n int) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("auto lo\niface lo inet loopback\n\n")
+	for i := 1; i <= n; i++ {
+		_, _ = fmt.Fprintf(buf, "auto eth0.%d\niface eth0.%d inet static\n", i, i)
+		_, _ = fmt.Fprintf(buf, "\taddress 10.%d.%d.1\n\tnetmask 255.255.255.0\n", i/256, i%256)
+		buf.WriteString("\tvlan-raw-device eth0\n\n")
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkMultiParser_Parse(b *testing.B) {
+	data := vlanFile(4000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mp := NewMultiParser()
+		_, _ = mp.Write(data)
+		if _, err := mp.Parse(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoder_Decode(b *testing.B) {
+	data := vlanFile(4000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dec := NewDecoder(bytes.NewReader(data))
+		for {
+			_, err := dec.Decode()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkInterfaces_String(b *testing.B) {
+	mp := NewMultiParser()
+	_, _ = mp.Write(vlanFile(4000))
+	ifaces, err := mp.Parse()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = ifaces.String()
+	}
+}
+
+func BenchmarkEncoder_Encode(b *testing.B) {
+	mp := NewMultiParser()
+	_, _ = mp.Write(vlanFile(4000))
+	ifaces, err := mp.Parse()
+	if err != nil {
+		b.Fatal(err)
+	}
+	names := ifaces.names()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		enc := NewEncoder(io.Discard)
+		for _, name := range names {
+			if err = enc.Encode(ifaces[name]); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err = enc.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
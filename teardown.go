@@ -0,0 +1,292 @@
+package ifupdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Teardown is an up hook that no down hook undoes, along with the command
+// that would.
+type Teardown struct {
+	// Phase is the up phase the hook runs in.
+	Phase HookPhase `json:"phase"`
+	// Hook is the up hook as written.
+	Hook string `json:"hook"`
+	// Inverse is the command that undoes Hook.
+	Inverse string `json:"inverse"`
+}
+
+// DownPhase returns the phase Inverse belongs in, mirroring Phase: what
+// post-up adds is removed in pre-down, what pre-up adds in post-down.
+func (t Teardown) DownPhase() HookPhase {
+	if t.Phase == PhasePreUp {
+		return PhasePostDown
+	}
+	return PhasePreDown
+}
+
+// hookAction is what a hook recognised by teardown analysis does: it adds or
+// removes the object key names.
+type hookAction struct {
+	key string
+	add bool
+	// inverse removes the object again, for hooks that add it.
+	inverse string
+}
+
+// parseHookAction recognises hooks that add or remove routes, rules,
+// addresses and links with ip, routes with route, and rules and chains with
+// iptables and ip6tables.
+func parseHookAction(command string) (hookAction, bool) {
+	if r, verb, ok := parseRouteCommand(command); ok {
+		table := r.Table
+		if table == "main" || table == "254" {
+			table = ""
+		}
+		return hookAction{key: "route " + table + " " + r.Destination.String(), add: verb == "add", inverse: r.DelCommand()}, true
+	}
+	if r, verb, ok := parseRuleCommand(command); ok {
+		return hookAction{key: "rule " + r.ipFlag() + r.selector(), add: verb == "add", inverse: r.DelCommand()}, true
+	}
+	args, ok := commandArgs(command)
+	if !ok {
+		return hookAction{}, false
+	}
+	switch program(args[0]) {
+	case "ip":
+		return parseIPAction(args[1:])
+	case "iptables", "ip6tables":
+		return parseIPTablesAction(program(args[0]), args[1:])
+	default:
+		return hookAction{}, false
+	}
+}
+
+// parseIPAction recognises ip address and ip link commands that add or delete
+// an address or a link, from the arguments of ip after its name.
+func parseIPAction(args []string) (hookAction, bool) {
+	family, args, ok := ipFamily(args)
+	if !ok || len(args) < 3 {
+		return hookAction{}, false
+	}
+	verb := ipVerb(args[1])
+	if verb == "" {
+		return hookAction{}, false
+	}
+	flag := ""
+	if family != 0 {
+		flag = "-" + strconv.Itoa(family) + " "
+	}
+
+	switch {
+	case isObject(args[0], "address"):
+		var local, dev string
+		for i := 2; i < len(args); i++ {
+			switch {
+			case args[i] == "dev" && i+1 < len(args):
+				i++
+				dev = args[i]
+			case args[i] == "local" && i+1 < len(args):
+				i++
+				local = args[i]
+			case i == 2:
+				local = args[i]
+			}
+		}
+		if local == "" || dev == "" {
+			return hookAction{}, false
+		}
+		return hookAction{
+			key:     "address " + local + " " + dev,
+			add:     verb == "add",
+			inverse: "ip " + flag + "address del " + quoteArg(local) + " dev " + quoteArg(dev),
+		}, true
+	case isObject(args[0], "link"):
+		var name string
+	link:
+		for i := 2; i < len(args); i++ {
+			switch args[i] {
+			case "type":
+				break link
+			case "name", "dev":
+				if i+1 < len(args) {
+					name = args[i+1]
+				}
+				i++
+			case "link", "group", "mtu", "address", "txqueuelen", "numtxqueues", "numrxqueues":
+				i++
+			default:
+				if name == "" {
+					name = args[i]
+				}
+			}
+		}
+		if name == "" {
+			return hookAction{}, false
+		}
+		return hookAction{key: "link " + name, add: verb == "add", inverse: "ip link del " + quoteArg(name)}, true
+	default:
+		return hookAction{}, false
+	}
+}
+
+// parseIPTablesAction recognises iptables commands that append, insert or
+// delete a rule, or create or delete a chain, from the arguments of prog
+// after its name.
+func parseIPTablesAction(prog string, args []string) (hookAction, bool) {
+	table := "filter"
+	var command, chain string
+	var rule []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-w", "--wait":
+			if i+1 < len(args) && strings.Trim(args[i+1], "0123456789.") == "" {
+				i++
+			}
+			continue
+		case "-t", "--table", "-A", "--append", "-I", "--insert", "-D", "--delete",
+			"-N", "--new-chain", "-X", "--delete-chain":
+		default:
+			rule = append(rule, arg)
+			continue
+		}
+		if i+1 >= len(args) || command != "" && arg != "-t" && arg != "--table" {
+			return hookAction{}, false
+		}
+		i++
+		if arg == "-t" || arg == "--table" {
+			table = args[i]
+			continue
+		}
+		chain = args[i]
+		switch arg {
+		case "-A", "--append":
+			command = "-A"
+		case "-I", "--insert":
+			command = "-I"
+			if i+1 < len(args) && strings.Trim(args[i+1], "0123456789") == "" {
+				// the rule number only says where the rule goes
+				i++
+			}
+		case "-D", "--delete":
+			command = "-D"
+		case "-N", "--new-chain":
+			command = "-N"
+		case "-X", "--delete-chain":
+			command = "-X"
+		}
+	}
+
+	tableFlag := ""
+	if table != "filter" {
+		tableFlag = " -t " + quoteArg(table)
+	}
+	switch command {
+	case "-A", "-I", "-D":
+		if len(rule) == 0 {
+			return hookAction{}, false
+		}
+		spec := quoteArgs(rule)
+		return hookAction{
+			key:     prog + " " + table + " " + chain + " " + spec,
+			add:     command != "-D",
+			inverse: prog + tableFlag + " -D " + quoteArg(chain) + " " + spec,
+		}, true
+	case "-N", "-X":
+		if len(rule) != 0 {
+			return hookAction{}, false
+		}
+		return hookAction{
+			key:     prog + " " + table + " chain " + chain,
+			add:     command == "-N",
+			inverse: prog + tableFlag + " -X " + quoteArg(chain),
+		}, true
+	default:
+		return hookAction{}, false
+	}
+}
+
+// ifaceVariable matches $IFACE and ${IFACE}, but not longer names.
+var ifaceVariable = regexp.MustCompile(`\$IFACE\b|\$\{IFACE\}`)
+
+// expandName replaces $IFACE and ${IFACE} in s with the name of iface.
+func (iface *NetworkInterface) expandName(s string) string {
+	return ifaceVariable.ReplaceAllLiteralString(s, iface.Name)
+}
+
+// hookAction parses hook with parseHookAction. The key is that of hook with
+// $IFACE replaced by the name of iface, so that hooks naming the interface
+// either way match; the inverse keeps the spelling of hook. The caller must
+// hold at least the read lock.
+func (iface *NetworkInterface) hookAction(hook string) (hookAction, bool) {
+	action, ok := parseHookAction(hook)
+	if !ok {
+		return action, false
+	}
+	if named, ok := parseHookAction(iface.expandName(hook)); ok {
+		action.key = named.key
+	}
+	return action, true
+}
+
+// MissingTeardowns returns the pre-up and post-up hooks of iface that add a
+// route, rule, address, link, firewall rule or chain no down hook removes,
+// in the order they run.
+func (iface *NetworkInterface) MissingTeardowns() []Teardown {
+	iface.mu.RLock()
+	defer iface.mu.RUnlock()
+	return iface.missingTeardowns()
+}
+
+// removedKeys returns the keys of what the down hooks of iface remove. The
+// caller must hold at least the read lock.
+func (iface *NetworkInterface) removedKeys() map[string]bool {
+	removed := make(map[string]bool)
+	for _, hooks := range [][]string{iface.Hooks.PreDown, iface.Hooks.PostDown} {
+		for _, hook := range hooks {
+			if action, ok := iface.hookAction(hook); ok && !action.add {
+				removed[action.key] = true
+			}
+		}
+	}
+	return removed
+}
+
+func (iface *NetworkInterface) missingTeardowns() []Teardown {
+	removed := iface.removedKeys()
+
+	var missing []Teardown
+	for _, phase := range []HookPhase{PhasePreUp, PhasePostUp} {
+		for _, hook := range iface.Hooks.phase(phase) {
+			action, ok := iface.hookAction(hook)
+			if !ok || !action.add || removed[action.key] {
+				continue
+			}
+			// one teardown is enough for a hook that is repeated
+			removed[action.key] = true
+			missing = append(missing, Teardown{Phase: phase, Hook: hook, Inverse: action.inverse})
+		}
+	}
+	return missing
+}
+
+// WithTeardowns adds the inverse of every hook MissingTeardowns returns to
+// the down hooks of the interface, in reverse order so that objects are
+// removed before those they were added after.
+func (iface *NetworkInterface) WithTeardowns() *NetworkInterface {
+	iface.allocate()
+	missing := iface.missingTeardowns()
+	for i := len(missing) - 1; i >= 0; i-- {
+		t := missing[i]
+		switch t.DownPhase() {
+		case PhasePreDown:
+			iface.Hooks.PreDown = append(iface.Hooks.PreDown, t.Inverse)
+		case PhasePostDown:
+			iface.Hooks.PostDown = append(iface.Hooks.PostDown, t.Inverse)
+		}
+	}
+	iface.mu.Unlock()
+	return iface
+}
@@ -0,0 +1,132 @@
+package ifupdown
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseHookAction(t *testing.T) {
+	for command, want := range map[string]hookAction{
+		"ip route add 10.1.0.0/16 via 10.0.0.253 table main": {
+			key: "route  10.1.0.0/16", add: true, inverse: "ip route del 10.1.0.0/16 via 10.0.0.253 table main",
+		},
+		"route del -net 10.1.0.0 netmask 255.255.0.0": {key: "route  10.1.0.0/16"},
+		"ip rule add from 10.0.0.2 table 10": {
+			key: "rule  from 10.0.0.2 table 10", add: true, inverse: "ip rule del from 10.0.0.2 table 10",
+		},
+		"ip -6 addr add 2001:db8::2/64 dev $IFACE": {
+			key: "address 2001:db8::2/64 $IFACE", add: true, inverse: "ip -6 address del 2001:db8::2/64 dev $IFACE",
+		},
+		"ip a del local 10.0.0.3/24 brd + dev eth0": {key: "address 10.0.0.3/24 eth0"},
+		"ip link add link eth0 name eth0.10 type vlan id 10": {
+			key: "link eth0.10", add: true, inverse: "ip link del eth0.10",
+		},
+		"ip link delete dev eth0.10": {key: "link eth0.10"},
+		"/sbin/iptables -w -t nat -A POSTROUTING -o $IFACE -j MASQUERADE || true": {
+			key:     "iptables nat POSTROUTING -o $IFACE -j MASQUERADE",
+			add:     true,
+			inverse: "iptables -t nat -D POSTROUTING -o $IFACE -j MASQUERADE",
+		},
+		"ip6tables -I INPUT 1 -i eth0 -p tcp --dport 22 -j ACCEPT": {
+			key:     "ip6tables filter INPUT -i eth0 -p tcp --dport 22 -j ACCEPT",
+			add:     true,
+			inverse: "ip6tables -D INPUT -i eth0 -p tcp --dport 22 -j ACCEPT",
+		},
+		"iptables -N wan-in": {key: "iptables filter chain wan-in", add: true, inverse: "iptables -X wan-in"},
+		"iptables -A INPUT -m comment --comment 'allow ssh' -j ACCEPT": {
+			key:     "iptables filter INPUT -m comment --comment 'allow ssh' -j ACCEPT",
+			add:     true,
+			inverse: "iptables -D INPUT -m comment --comment 'allow ssh' -j ACCEPT",
+		},
+	} {
+		got, ok := parseHookAction(command)
+		if !ok {
+			t.Errorf("parseHookAction(%q) failed", command)
+			continue
+		}
+		if !want.add {
+			// only adds carry an inverse
+			got.inverse = ""
+		}
+		if got != want {
+			t.Errorf("parseHookAction(%q) = %+v, want %+v", command, got, want)
+		}
+	}
+
+	for _, command := range []string{
+		"ip link set dev eth0 up",
+		"ip addr add 10.0.0.3/24",
+		"iptables -P INPUT DROP",
+		"iptables -A INPUT",
+		"iptables -A INPUT -D OUTPUT -j DROP",
+		`iptables -A INPUT -m comment --comment "up $IFACE" -j ACCEPT`,
+		"sysctl -w net.ipv4.ip_forward=1",
+	} {
+		if action, ok := parseHookAction(command); ok {
+			t.Errorf("parseHookAction(%q) = %+v, want nothing recognised", command, action)
+		}
+	}
+}
+
+const teardownFile = `iface eth0 inet static
+	address 10.0.0.2/24
+	pre-up ip link add link eth9 name eth0 type vlan id 10
+	post-up ip route add 10.1.0.0/16 via 10.0.0.253
+	post-up iptables -t nat -A POSTROUTING -o $IFACE -j MASQUERADE
+	post-up ip route add 10.2.0.0/16 via 10.0.0.253
+	post-up echo up
+	pre-down ip route del 10.1.0.0/16
+`
+
+func TestNetworkInterface_MissingTeardowns(t *testing.T) {
+	eth0 := parseInterfaces(t, teardownFile)["eth0"]
+	missing := eth0.MissingTeardowns()
+	var hooks []string
+	for _, td := range missing {
+		hooks = append(hooks, string(td.Phase)+" "+td.Hook)
+	}
+	if want := []string{
+		"pre-up ip link add link eth9 name eth0 type vlan id 10",
+		"post-up iptables -t nat -A POSTROUTING -o $IFACE -j MASQUERADE",
+		"post-up ip route add 10.2.0.0/16 via 10.0.0.253",
+	}; !slices.Equal(hooks, want) {
+		t.Fatalf("MissingTeardowns() = %q, want %q", hooks, want)
+	}
+	if missing[0].DownPhase() != PhasePostDown || missing[1].DownPhase() != PhasePreDown {
+		t.Errorf("DownPhase() = %s, %s", missing[0].DownPhase(), missing[1].DownPhase())
+	}
+
+	eth0.WithTeardowns()
+	if want := []string{
+		"ip route del 10.1.0.0/16",
+		"ip route del 10.2.0.0/16 via 10.0.0.253",
+		"iptables -t nat -D POSTROUTING -o $IFACE -j MASQUERADE",
+	}; !slices.Equal(eth0.Hooks.PreDown, want) {
+		t.Errorf("pre-down after WithTeardowns() = %q, want %q", eth0.Hooks.PreDown, want)
+	}
+	if want := []string{"ip link del eth0"}; !slices.Equal(eth0.Hooks.PostDown, want) {
+		t.Errorf("post-down after WithTeardowns() = %q, want %q", eth0.Hooks.PostDown, want)
+	}
+	if missing := eth0.MissingTeardowns(); len(missing) != 0 {
+		t.Errorf("MissingTeardowns() after WithTeardowns() = %+v", missing)
+	}
+}
+
+func TestNetworkInterface_MissingTeardowns_IFACE(t *testing.T) {
+	eth0 := parseInterfaces(t, `iface eth0 inet static
+	address 10.0.0.2/24
+	post-up ip addr add 10.0.0.3/24 dev $IFACE
+	post-up iptables -A FORWARD -i ${IFACE} -j ACCEPT
+	post-up iptables -A FORWARD -i $IFACE_PEER -j ACCEPT
+	pre-down ip addr del 10.0.0.3/24 dev eth0
+	pre-down iptables -D FORWARD -i eth0 -j ACCEPT
+`)["eth0"]
+	missing := eth0.MissingTeardowns()
+	if len(missing) != 1 || missing[0].Inverse != "iptables -D FORWARD -i $IFACE_PEER -j ACCEPT" {
+		t.Fatalf("MissingTeardowns() = %+v", missing)
+	}
+	eth0.WithTeardowns()
+	if n := len(eth0.Hooks.PreDown); n != 3 {
+		t.Errorf("pre-down after WithTeardowns() = %q", eth0.Hooks.PreDown)
+	}
+}
@@ -0,0 +1,85 @@
+package ifupdown
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// lineReader reads the logical lines of an interfaces file. Like ifupdown, it
+// joins a line that ends in a backslash with the one after it.
+type lineReader struct {
+	xerox *bufio.Scanner
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{xerox: bufio.NewScanner(r)}
+}
+
+// next returns the next logical line, without the backslashes that joined it.
+func (lr *lineReader) next() (string, bool) {
+	if !lr.xerox.Scan() {
+		return "", false
+	}
+	line := lr.xerox.Text()
+	for strings.HasSuffix(line, `\`) {
+		line = line[:len(line)-1]
+		if !lr.xerox.Scan() {
+			break
+		}
+		line += lr.xerox.Text()
+	}
+	return line, true
+}
+
+func (lr *lineReader) err() error {
+	return lr.xerox.Err()
+}
+
+// stripComment removes the comment from line. A line whose first word starts
+// with # is a comment as a whole. Elsewhere a # that starts a word outside of
+// quotes starts a trailing comment, except on hook lines, where # belongs to
+// the shell, and on wpa-* and wireless-* lines, whose values, like
+// "wpa-ssid Cafe #2", may hold a # of their own.
+func stripComment(line string) string {
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 0:
+		return line
+	case strings.HasPrefix(fields[0], "#"):
+		return ""
+	case hookKeyword(fields[0]), strings.HasPrefix(fields[0], "wpa-"), strings.HasPrefix(fields[0], "wireless-"):
+		return line
+	}
+	var quote byte
+	for i := 1; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '#' && (line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// normalize strips the comment and surrounding whitespace from line.
+func normalize(line string) string {
+	return strings.TrimSpace(stripComment(line))
+}
+
+// tokens splits line into its words, however they are separated, leaving out
+// any comment.
+func tokens(line string) []string {
+	return strings.Fields(stripComment(line))
+}
+
+// value returns what follows the keyword of a normalized line.
+func value(normalized string) string {
+	return strings.TrimSpace(strings.TrimPrefix(normalized, keyword(normalized)))
+}
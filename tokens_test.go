@@ -0,0 +1,133 @@
+package ifupdown
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStripComment(t *testing.T) {
+	for line, want := range map[string]string{
+		"# comment":                         "",
+		"\t#indented comment":               "",
+		"auto lo eth0 # the usual":          "auto lo eth0 ",
+		"iface eth0 inet static\t#uplink":   "iface eth0 inet static\t",
+		"address 10.0.0.1 # home":           "address 10.0.0.1 ",
+		"dns-search lan\t#home":             "dns-search lan\t",
+		`description "rack #4" # spare`:     `description "rack #4" `,
+		"mtu 9000#jumbo":                    "mtu 9000#jumbo",
+		"up echo foo # bar":                 "up echo foo # bar",
+		"post-down logger '#down'":          "post-down logger '#down'",
+		"wpa-ssid Cafe #2":                  "wpa-ssid Cafe #2",
+		"hwaddress ether 52:54:00:12:34:56": "hwaddress ether 52:54:00:12:34:56",
+	} {
+		if got := stripComment(line); got != want {
+			t.Errorf("stripComment(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestMultiParser_HashInValue(t *testing.T) {
+	wlan0 := parseInterfaces(t, `iface wlan0 inet dhcp
+	# the cafe next door
+	wpa-ssid Cafe #2
+	wpa-psk hunter22 #secret
+	post-up echo "#up"
+`)["wlan0"]
+	if w := wlan0.Wireless; w == nil || w.SSID != "Cafe #2" || w.PSK != "hunter22 #secret" {
+		t.Fatalf("wireless = %+v", wlan0.Wireless)
+	}
+	if !slices.Equal(wlan0.Hooks.PostUp, []string{`echo "#up"`}) {
+		t.Errorf("post-up = %q", wlan0.Hooks.PostUp)
+	}
+	if got := wlan0.String(); !strings.Contains(got, "\twpa-ssid Cafe #2\n") {
+		t.Errorf("String() =\n%s", got)
+	}
+}
+
+func TestMultiParser_Whitespace(t *testing.T) {
+	ifaces := parseInterfaces(t, "auto  lo\teth0   # the usual\n"+
+		"iface lo inet loopback\n"+
+		"\n"+
+		"iface\teth0  inet   static # uplink\n"+
+		"    address  10.0.0.1\n"+
+		"\tnetmask\t255.255.255.0\t\n"+
+		"\t# router\n"+
+		"\tgateway 10.0.0.254 # the router\n"+
+		"\tdns-nameservers  1.1.1.1 \\\n"+
+		"\t\t8.8.8.8\n"+
+		"\tup ip route add 10.1.0.0/16 \\\n"+
+		"\t\tvia 10.0.0.253\n"+
+		"  # no ports\n"+
+		"\tbridge-ports none # no ports\n")
+
+	eth0 := ifaces["eth0"]
+	if eth0 == nil {
+		t.Fatalf("eth0 missing: %v", ifaces.names())
+	}
+	if err := eth0.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if !eth0.Auto || !ifaces["lo"].Auto {
+		t.Error("auto line with tabs and a comment lost its interfaces")
+	}
+	if eth0.Address.String() != "10.0.0.1" || eth0.Netmask.String() != "ffffff00" || eth0.Gateway.String() != "10.0.0.254" {
+		t.Errorf("eth0 = %s/%s via %s", eth0.Address, eth0.Netmask, eth0.Gateway)
+	}
+	if len(eth0.DNSServers) != 2 || eth0.DNSServers[1].String() != "8.8.8.8" {
+		t.Errorf("dns-nameservers = %v", eth0.DNSServers)
+	}
+	if !slices.Equal(eth0.Hooks.PostUp, []string{"ip route add 10.1.0.0/16 \t\tvia 10.0.0.253"}) {
+		t.Errorf("post-up = %q", eth0.Hooks.PostUp)
+	}
+	if v, _ := eth0.lookup("bridge-ports"); v != "none" {
+		t.Errorf("bridge-ports = %q, want none", v)
+	}
+}
+
+var roundTripSeeds = []string{
+	"auto lo\niface lo inet loopback\n",
+	"auto lo eth0\niface lo inet loopback\n\niface eth0 inet static\n\taddress 10.0.0.1/24\n\tgateway 10.0.0.254\n",
+	"allow-hotplug eth1\niface eth1 inet dhcp\n\thwaddress ether 52:54:00:12:34:56\n\tpre-up echo hi # there\n",
+	"iface br0 inet manual\n\tbridge-ports eth0 \\\n\t\teth1\n\tbridge-stp off\n",
+	"mapping eth0\n\tscript /bin/true\n\tmap HOME eth0-home\n\niface eth0-home inet dhcp\n",
+	"iface eth0.100 inet6 static\n\taddress 2001:db8::1/64\n\tdns-search example.com  example.net\n",
+	"iface gre1 inet tunnel\n\taddress 10.8.0.1\n\tmode GRE\n\tendpoint 198.51.100.7\n\tttl 64\n",
+	"iface wlan0 inet6 auto\n\taccept_ra 2\n\twpa-ssid home\n\twpa-psk 12345678\n",
+}
+
+// FuzzMultiParser_RoundTrip checks that rendering what was parsed, then
+// parsing and rendering that again, gives the same output.
+func FuzzMultiParser_RoundTrip(f *testing.F) {
+	for _, seed := range roundTripSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data string) {
+		mp := NewMultiParser()
+		_, _ = mp.Write([]byte(data))
+		ifaces, err := mp.Parse()
+		if err != nil {
+			return
+		}
+		first := &bytes.Buffer{}
+		if _, err = ifaces.WriteTo(first); err != nil {
+			// not every parsed stanza is valid
+			return
+		}
+
+		mp = NewMultiParser()
+		_, _ = mp.Write(first.Bytes())
+		again, err := mp.Parse()
+		if err != nil {
+			t.Fatalf("parsing rendered output: %v\n%s", err, first)
+		}
+		second := &bytes.Buffer{}
+		if _, err = again.WriteTo(second); err != nil {
+			t.Fatalf("rendering again: %v\n%s", err, first)
+		}
+		if first.String() != second.String() {
+			t.Fatalf("round trip changed output:\n%s\nbecame:\n%s", first, second)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package ifupdown
 
 import (
 	"bytes"
+	"io"
 	"strings"
 	"sync"
 )
@@ -54,3 +55,15 @@ func newBufs() bufs {
 var bufPools = newBufs()
 
 var pools = poolGroup{Buffers: bufPools.buffers, Strs: bufPools.strings}
+
+// countingWriter keeps track of how many bytes have been written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
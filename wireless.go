@@ -0,0 +1,209 @@
+package ifupdown
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Redacted replaces secrets in the output of Redacted.
+const Redacted = "REDACTED"
+
+// Wireless holds the wpa-* options of wpasupplicant and the wireless-*
+// options of wireless-tools. Other options of either package are kept in
+// Options like any unknown option.
+type Wireless struct {
+	// SSID of the network to join, wpa-ssid.
+	SSID string `json:"ssid,omitempty"`
+	// PSK is the pre-shared key, wpa-psk: a passphrase of 8 to 63
+	// characters or 64 hex digits.
+	PSK string `json:"psk,omitempty"`
+	// Password for EAP authentication, wpa-password.
+	Password string `json:"password,omitempty"`
+	// Conf names a wpa_supplicant.conf to use instead of the wpa-* options,
+	// wpa-conf.
+	Conf string `json:"conf,omitempty"`
+	// KeyMgmt lists the accepted key management protocols, wpa-key-mgmt.
+	KeyMgmt []string `json:"key_mgmt,omitempty"`
+	// ESSID of the network to join, wireless-essid.
+	ESSID string `json:"essid,omitempty"`
+	// Mode of the card, such as managed or ad-hoc, wireless-mode.
+	Mode string `json:"mode,omitempty"`
+	// Key is the WEP key, wireless-key.
+	Key string `json:"key,omitempty"`
+}
+
+// keyMgmtProtocols are the values wpa_supplicant accepts for key_mgmt.
+var keyMgmtProtocols = []string{
+	"NONE", "WPA-PSK", "WPA-EAP", "IEEE8021X", "FT-PSK", "FT-EAP",
+	"WPA-PSK-SHA256", "WPA-EAP-SHA256", "SAE", "FT-SAE", "OWE",
+	"WPA-EAP-SUITE-B", "WPA-EAP-SUITE-B-192", "DPP",
+}
+
+// secretOptions are the options without a field of their own that still
+// carry secrets, and get redacted along with the fields of Wireless.
+var secretOptions = []string{
+	"wpa-private-key-passwd", "wpa-private-key2-passwd", "wpa-pin",
+	"wpa-wep-key0", "wpa-wep-key1", "wpa-wep-key2", "wpa-wep-key3",
+	"wireless-key1", "wireless-key2", "wireless-key3", "wireless-key4",
+}
+
+func init() {
+	registerOption("wpa-ssid", wirelessOption(func(w *Wireless, v string) { w.SSID = v }))
+	registerOption("wpa-psk", wirelessOption(func(w *Wireless, v string) { w.PSK = v }))
+	registerOption("wpa-password", wirelessOption(func(w *Wireless, v string) { w.Password = v }))
+	registerOption("wpa-conf", wirelessOption(func(w *Wireless, v string) { w.Conf = v }))
+	registerOption("wpa-key-mgmt", wirelessOption(func(w *Wireless, v string) {
+		w.KeyMgmt = append(w.KeyMgmt, strings.Fields(v)...)
+	}))
+	registerOption("wireless-essid", wirelessOption(func(w *Wireless, v string) { w.ESSID = v }))
+	registerOption("wireless-mode", wirelessOption(func(w *Wireless, v string) { w.Mode = v }))
+	registerOption("wireless-key", wirelessOption(func(w *Wireless, v string) { w.Key = v }))
+}
+
+// wirelessOption returns a parser that hands the value to set, creating the
+// Wireless of the interface on first use.
+func wirelessOption(set func(w *Wireless, value string)) optionParser {
+	return func(iface *NetworkInterface, value string) error {
+		if value == "" {
+			return nil
+		}
+		if iface.Wireless == nil {
+			iface.Wireless = &Wireless{}
+		}
+		set(iface.Wireless, value)
+		return nil
+	}
+}
+
+// Clone returns a deep copy of w.
+func (w *Wireless) Clone() *Wireless {
+	if w == nil {
+		return nil
+	}
+	clone := *w
+	clone.KeyMgmt = slices.Clone(w.KeyMgmt)
+	return &clone
+}
+
+// Validate checks the length and form of the keys and names, and that the
+// options do not contradict each other.
+func (w *Wireless) Validate() error {
+	if w == nil {
+		return nil
+	}
+	if w.PSK != "" && !validPSK(w.PSK) {
+		return fmt.Errorf("%w: want 8 to 63 characters or 64 hex digits, got %d characters", ErrInvalidPSK, len(w.PSK))
+	}
+	if len(w.SSID) > 32 {
+		return fmt.Errorf("%w: wpa-ssid is longer than 32 bytes", ErrInvalidWireless)
+	}
+	if len(w.ESSID) > 32 {
+		return fmt.Errorf("%w: wireless-essid is longer than 32 bytes", ErrInvalidWireless)
+	}
+	if w.Conf != "" && (w.SSID != "" || w.PSK != "" || w.Password != "" || len(w.KeyMgmt) > 0) {
+		return fmt.Errorf("%w: wpa-conf cannot be combined with other wpa-* options", ErrInvalidWireless)
+	}
+	for _, proto := range w.KeyMgmt {
+		if !slices.Contains(keyMgmtProtocols, proto) {
+			return fmt.Errorf("%w: unknown wpa-key-mgmt %s", ErrInvalidWireless, proto)
+		}
+	}
+	if w.PSK != "" && slices.Equal(w.KeyMgmt, []string{"NONE"}) {
+		return fmt.Errorf("%w: wpa-psk set with wpa-key-mgmt NONE", ErrInvalidWireless)
+	}
+	return nil
+}
+
+// validPSK reports whether psk is 64 hex digits or a passphrase of 8 to 63
+// printable ASCII characters, the two forms wpa_supplicant takes.
+func validPSK(psk string) bool {
+	if len(psk) == 64 {
+		for _, c := range psk {
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+		return true
+	}
+	if len(psk) < 8 || len(psk) > 63 {
+		return false
+	}
+	for _, c := range psk {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// options lists the wireless options in the order they are written.
+func (w *Wireless) options() []Option {
+	if w == nil {
+		return nil
+	}
+	var opts []Option
+	add := func(key, value string) {
+		if value != "" {
+			opts = append(opts, Option{Key: key, Value: value})
+		}
+	}
+	add("wpa-conf", w.Conf)
+	add("wpa-ssid", w.SSID)
+	add("wpa-key-mgmt", strings.Join(w.KeyMgmt, " "))
+	add("wpa-psk", w.PSK)
+	add("wpa-password", w.Password)
+	add("wireless-essid", w.ESSID)
+	add("wireless-mode", w.Mode)
+	add("wireless-key", w.Key)
+	return opts
+}
+
+// redact replaces the secrets of w with Redacted.
+func (w *Wireless) redact() {
+	if w == nil {
+		return
+	}
+	for _, secret := range []*string{&w.PSK, &w.Password, &w.Key} {
+		if *secret != "" {
+			*secret = Redacted
+		}
+	}
+}
+
+// Redacted returns a copy of iface with its secrets, such as wpa-psk and the
+// secret options of option families, replaced by Redacted, so it can be
+// logged or attached to a ticket.
+func (iface *NetworkInterface) Redacted() *NetworkInterface {
+	clone := iface.Clone()
+	clone.Wireless.redact()
+	clone.redactExtensions()
+	if clone.Dual != nil {
+		clone.Dual = clone.Dual.Redacted()
+	}
+	for i, opt := range clone.Options {
+		if slices.Contains(secretOptions, opt.Key) {
+			clone.Options[i].Value = Redacted
+		}
+	}
+	return clone
+}
+
+// Redacted returns a copy of i with the secrets of every interface replaced.
+func (i Interfaces) Redacted() Interfaces {
+	redacted := make(Interfaces, len(i))
+	for name, iface := range i {
+		if iface != nil {
+			redacted[name] = iface.Redacted()
+		}
+	}
+	return redacted
+}
+
+// WithWireless sets the wireless options of the interface.
+func (iface *NetworkInterface) WithWireless(w *Wireless) *NetworkInterface {
+	iface.allocate()
+	iface.Wireless = w.Clone()
+	iface.mu.Unlock()
+	return iface
+}
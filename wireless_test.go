@@ -0,0 +1,126 @@
+package ifupdown
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const wirelessFile = `auto wlan0
+iface wlan0 inet dhcp
+	wpa-ssid Field Net 3
+	wpa-key-mgmt WPA-PSK
+	wpa-psk correct horse battery
+	wpa-ap-scan 1
+	wpa-wep-key0 0123456789
+
+iface wlan1 inet manual
+	wireless-essid legacy
+	wireless-mode managed
+	wireless-key s:abcde
+`
+
+func TestNetworkInterface_Wireless(t *testing.T) {
+	ifaces := parseInterfaces(t, wirelessFile)
+
+	w := ifaces["wlan0"].Wireless
+	if w == nil || w.SSID != "Field Net 3" || w.PSK != "correct horse battery" || len(w.KeyMgmt) != 1 {
+		t.Fatalf("wlan0 wireless = %+v", w)
+	}
+	if v, _ := ifaces["wlan0"].lookup("wpa-ap-scan"); v != "1" {
+		t.Errorf("wpa-ap-scan = %q, want it kept in Options", v)
+	}
+	if w := ifaces["wlan1"].Wireless; w == nil || w.ESSID != "legacy" || w.Key != "s:abcde" {
+		t.Errorf("wlan1 wireless = %+v", w)
+	}
+	for _, name := range []string{"wlan0", "wlan1"} {
+		if err := ifaces[name].Validate(); err != nil {
+			t.Errorf("[%s] Validate() = %v", name, err)
+		}
+	}
+
+	again := parseInterfaces(t, ifaces.String())
+	if got, want := again.String(), ifaces.String(); got != want {
+		t.Errorf("round trip:\n%s\nwant:\n%s", got, want)
+	}
+
+	clone := ifaces["wlan0"].Clone()
+	clone.Wireless.KeyMgmt[0] = "SAE"
+	if w.KeyMgmt[0] != "WPA-PSK" {
+		t.Error("Clone() shares Wireless with the original")
+	}
+}
+
+func TestWireless_Validate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		w    Wireless
+		want error
+	}{
+		"passphrase":      {Wireless{SSID: "x", PSK: "12345678"}, nil},
+		"hex":             {Wireless{SSID: "x", PSK: strings.Repeat("aB", 32)}, nil},
+		"short":           {Wireless{SSID: "x", PSK: "1234567"}, ErrInvalidPSK},
+		"long":            {Wireless{SSID: "x", PSK: strings.Repeat("a", 64) + "z"}, ErrInvalidPSK},
+		"not hex":         {Wireless{SSID: "x", PSK: strings.Repeat("g", 64)}, ErrInvalidPSK},
+		"control":         {Wireless{SSID: "x", PSK: "12345678\x01"}, ErrInvalidPSK},
+		"long ssid":       {Wireless{SSID: strings.Repeat("s", 33)}, ErrInvalidWireless},
+		"conf and inline": {Wireless{Conf: "/etc/wpa.conf", SSID: "x"}, ErrInvalidWireless},
+		"key mgmt":        {Wireless{SSID: "x", KeyMgmt: []string{"WPA-PSK", "WEP"}}, ErrInvalidWireless},
+		"psk without key": {Wireless{SSID: "x", PSK: "12345678", KeyMgmt: []string{"NONE"}}, ErrInvalidWireless},
+	} {
+		if err := tc.w.Validate(); !errors.Is(err, tc.want) || (tc.want == nil && err != nil) {
+			t.Errorf("%s: Validate() = %v, want %v", name, err, tc.want)
+		}
+	}
+
+	iface := parseInterfaces(t, "iface wlan0 inet dhcp\n\twpa-psk short\n")["wlan0"]
+	if err := iface.Validate(); !errors.Is(err, ErrInvalidPSK) {
+		t.Errorf("Validate() = %v, want %v", err, ErrInvalidPSK)
+	}
+}
+
+func TestInterfaces_Redacted(t *testing.T) {
+	ifaces := parseInterfaces(t, wirelessFile)
+	redacted := ifaces.Redacted()
+
+	out := redacted.String()
+	for _, secret := range []string{"correct horse battery", "0123456789", "s:abcde"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("String() leaks %q:\n%s", secret, out)
+		}
+	}
+	for _, line := range []string{"\twpa-psk REDACTED\n", "\twpa-wep-key0 REDACTED\n", "\twireless-key REDACTED\n", "\twpa-ssid Field Net 3\n"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("String() lacks %q:\n%s", line, out)
+		}
+	}
+
+	data, err := json.Marshal(redacted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "correct horse") || !strings.Contains(string(data), `"psk":"REDACTED"`) {
+		t.Errorf("MarshalJSON() = %s", data)
+	}
+
+	if ifaces["wlan0"].Wireless.PSK != "correct horse battery" {
+		t.Error("Redacted() changed the original")
+	}
+
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	enc.SetRedact(true)
+	if err := enc.Encode(ifaces["wlan0"]); err != nil {
+		t.Fatalf("Encode() = %v", err)
+	}
+	_ = enc.Flush()
+	if out := buf.String(); strings.Contains(out, "correct horse") || !strings.HasPrefix(out, "auto wlan0\n") ||
+		!strings.Contains(out, "\twpa-psk REDACTED\n") {
+		t.Errorf("Encode() with SetRedact wrote:\n%s", out)
+	}
+	short := parseInterfaces(t, "iface wlan0 inet dhcp\n\twpa-psk short\n")["wlan0"]
+	if err := enc.Encode(short); !errors.Is(err, ErrInvalidPSK) {
+		t.Errorf("Encode() of a short wpa-psk with SetRedact = %v, want %v", err, ErrInvalidPSK)
+	}
+}